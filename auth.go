@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenExpiryMargin is how long before expiration a cached token is refreshed.
+const tokenExpiryMargin = 60 * time.Second
+
+// defaultTokenLifetime is used when a token response does not include expires_in.
+const defaultTokenLifetime = 5 * time.Minute
+
+// AuthConfig represents the config data for an authentication provider.
+type AuthConfig struct {
+	Type   string
+	Config map[string]interface{}
+}
+
+// Authenticator defines the interface that request authentication providers need to implement. The transport is the
+// one used by the Endpoints the Authenticator is defined for, so any requests it makes share their TLS and proxy
+// settings.
+type Authenticator interface {
+	initialize(map[string]interface{}, *http.Transport) error
+	authenticate(*http.Request) error
+}
+
+// OAuth2Authenticator authenticates requests with a bearer token obtained using the OAuth2 client credentials flow.
+type OAuth2Authenticator struct {
+	TokenURL     string
+	ClientID     string
+	clientSecret string
+	Scopes       []string
+	Params       map[string]string
+	client       *http.Client
+	mu           sync.Mutex
+	token        string
+	expiry       time.Time
+}
+
+func (o *OAuth2Authenticator) initialize(data map[string]interface{}, transport *http.Transport) error {
+	var ok bool
+	if o.TokenURL, ok = data["tokenurl"].(string); !ok {
+		return fmt.Errorf("oauth2 authentication requires a tokenurl")
+	}
+	if o.ClientID, ok = data["clientid"].(string); !ok {
+		return fmt.Errorf("oauth2 authentication requires a clientid")
+	}
+	if o.clientSecret, ok = data["clientsecret"].(string); !ok {
+		return fmt.Errorf("oauth2 authentication requires a clientsecret")
+	}
+	if scopes, ok := data["scopes"].([]interface{}); ok {
+		for _, s := range scopes {
+			o.Scopes = append(o.Scopes, fmt.Sprint(s))
+		}
+	}
+	o.Params = make(map[string]string)
+	if params, ok := data["params"].(map[interface{}]interface{}); ok {
+		for k, v := range params {
+			o.Params[fmt.Sprint(k)] = fmt.Sprint(v)
+		}
+	}
+	o.client = &http.Client{Transport: transport, Timeout: defaultTimeout}
+	return nil
+}
+
+func (o *OAuth2Authenticator) authenticate(req *http.Request) error {
+	token, err := o.getToken()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// getToken returns the cached token, requesting a new one if it is missing or close to expiring.
+func (o *OAuth2Authenticator) getToken() (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.token != "" && time.Now().Add(tokenExpiryMargin).Before(o.expiry) {
+		return o.token, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if len(o.Scopes) > 0 {
+		form.Set("scope", strings.Join(o.Scopes, " "))
+	}
+	for k, v := range o.Params {
+		form.Set(k, v)
+	}
+
+	req, err := http.NewRequest("POST", o.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("unable to create token request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(o.ClientID), url.QueryEscape(o.clientSecret))
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request to %v failed: %v", o.TokenURL, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("unable to read token response from %v: %v", o.TokenURL, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request to %v returned status %d: %v", o.TokenURL, resp.StatusCode, string(body))
+	}
+
+	var tr struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	err = json.Unmarshal(body, &tr)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse token response from %v: %v", o.TokenURL, err)
+	}
+	if tr.AccessToken == "" {
+		return "", fmt.Errorf("token response from %v did not include an access_token", o.TokenURL)
+	}
+
+	lifetime := defaultTokenLifetime
+	if tr.ExpiresIn > 0 {
+		lifetime = time.Duration(tr.ExpiresIn) * time.Second
+	}
+	o.token = tr.AccessToken
+	o.expiry = time.Now().Add(lifetime)
+
+	return o.token, nil
+}
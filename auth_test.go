@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tokenServer returns a TLS server that issues numbered tokens valid for the lifetime in seconds, or responds with
+// status if it is not 200.
+func tokenServer(lifetime int, status int) (*httptest.Server, *int32) {
+	var requests int32
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		if id, secret, _ := r.BasicAuth(); r.FormValue("grant_type") != "client_credentials" || id != "feedmonitor" || secret != "client-secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "token-%d", "expires_in": %d}`, n, lifetime)
+	})), &requests
+}
+
+func newTestAuthenticator(t *testing.T, server *httptest.Server) *OAuth2Authenticator {
+	o := &OAuth2Authenticator{}
+	// The transport of the test server trusts its certificate, which the default transport does not.
+	err := o.initialize(map[string]interface{}{
+		"tokenurl":     server.URL,
+		"clientid":     "feedmonitor",
+		"clientsecret": "client-secret",
+	}, server.Client().Transport.(*http.Transport))
+	if err != nil {
+		t.Fatalf("Unexpected error initializing the authenticator: %v", err)
+	}
+	return o
+}
+
+func TestOAuth2TokenCaching(t *testing.T) {
+
+	server, requests := tokenServer(3600, http.StatusOK)
+	defer server.Close()
+	o := newTestAuthenticator(t, server)
+
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest("GET", "http://www.example.com/data.json", nil)
+		if err := o.authenticate(req); err != nil {
+			t.Fatalf("Unexpected error authenticating: %v", err)
+		}
+		if auth := req.Header.Get("Authorization"); auth != "Bearer token-1" {
+			t.Errorf("Expected the cached token but got %v", auth)
+		}
+	}
+	if *requests != 1 {
+		t.Errorf("Expected 1 token request but got %d", *requests)
+	}
+
+	// A token that expires within the margin is refreshed.
+	o.expiry = time.Now().Add(tokenExpiryMargin / 2)
+	token, err := o.getToken()
+	if err != nil || token != "token-2" || *requests != 2 {
+		t.Errorf("Expected a new token when the cached one is about to expire but got %v after %d requests (%v)", token, *requests, err)
+	}
+}
+
+func TestOAuth2TokenLifetime(t *testing.T) {
+
+	// A token that is only valid for less than the margin is requested again each time.
+	server, requests := tokenServer(30, http.StatusOK)
+	defer server.Close()
+	o := newTestAuthenticator(t, server)
+
+	o.getToken()
+	o.getToken()
+	if *requests != 2 {
+		t.Errorf("Expected 2 token requests for a short lived token but got %d", *requests)
+	}
+}
+
+func TestOAuth2FetchErrorAuth(t *testing.T) {
+
+	tokens, _ := tokenServer(3600, http.StatusUnauthorized)
+	defer tokens.Close()
+	server, requests := flakyServer(0, http.StatusOK)
+	defer server.Close()
+
+	a, e, results := newTestEndpoint(t, server.URL)
+	e.Authenticator = newTestAuthenticator(t, tokens)
+
+	if _, err := fetchEndpoint(a, e, e.URL, nil); err == nil {
+		t.Error("Expected an error when the token request fails.")
+	}
+	epr := <-results
+	if epr.FetchError == nil || epr.FetchError.Class != FetchErrorAuth || epr.Valid() {
+		t.Errorf("Expected an authentication error but got %+v", epr.FetchError)
+	}
+	if *requests != 0 {
+		t.Errorf("Expected no request to the endpoint without a token but got %d", *requests)
+	}
+}
@@ -3,12 +3,13 @@ package main
 import (
 	"bytes"
 	"fmt"
-	"html/template"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -17,6 +18,12 @@ import (
 
 const urlSeparator = "|||"
 
+// defaultTimeout is the request timeout used when an Endpoint does not define one.
+const defaultTimeout = 30 * time.Second
+
+// defaultRetryBackoff is the delay before the first retry when an Endpoint defines retries but no backoff.
+const defaultRetryBackoff = 1 * time.Second
+
 // NotificationChannel is used to send notications to the notification processor.
 var NotificationChannel chan *Notification
 
@@ -32,37 +39,65 @@ const (
 
 // Configuration defines the structure of the configuration file and values.
 type Configuration struct {
-	LogLevel     string
-	LogFile      string
-	GitRoot      string
-	WebPort      int
-	WebRoot      string
-	AppConfigDir string
-	WebDevMode   bool
+	LogLevel            string
+	LogFile             string
+	GitRoot             string
+	WebPort             int
+	WebRoot             string
+	AppConfigDir        string
+	MaxConcurrentChecks int
+	WebDevMode          bool
 }
 
 // ApplicationConfig represents configuration data loaded from the configuration file for a specific application
 type ApplicationConfig struct {
-	Key        string
-	Name       string
-	Validators []ValidatorConfig
-	Notifiers  []NotifierConfig
-	Endpoints  []EndpointConfig
+	Key                 string
+	Name                string
+	MaxConcurrentChecks int
+	TLS                 *TLSConfig
+	Proxy               *ProxyConfig
+	Auth                *AuthConfig
+	Maintenance         []MaintenanceConfig // Maintenance windows that apply to every Endpoint.
+	Validators          []ValidatorConfig
+	Notifiers           []NotifierConfig
+	Endpoints           []EndpointConfig
 }
 
 // EndpointConfig represents configuration data loaded from the configuration file for a specific application
 type EndpointConfig struct {
-	Key             string
-	Name            string
-	URL             string
-	Method          string
-	RequestBody     string
-	Headers         map[string]string
-	Dynamic         bool
-	IgnoreRedirects bool
-	CheckInterval   int
-	Notifiers       []string
-	Validators      []string
+	Key                string
+	Name               string
+	URL                string
+	Method             string
+	RequestBody        string
+	Headers            map[string]string
+	Dynamic            bool
+	DynamicURLs        *DynamicURLConfig // Generates the dynamic URLs from an array in another Endpoint's data.
+	DynamicConcurrency int               // Number of dynamic URLs fetched at the same time.
+	DependsOn          []string          // Keys of Endpoints whose data this Endpoint uses, in addition to those found in its templates.
+	NotifyURLChanges   bool              // Notify when dynamic URLs appear or disappear.
+	StatusPolicy       string            // How the status of dynamic URLs is combined: all, any or threshold.
+	StatusThreshold    int               // Percentage of dynamic URLs that must be valid with the threshold policy.
+	Sample             int               // Number of dynamic URLs checked each cycle, or 0 to check every URL.
+	SampleMode         string            // How sampled URLs are chosen: rotate or random.
+	CoverageWindow     time.Duration     // Maximum time a sampled URL can go without being checked, ex: 1h
+	IgnoreRedirects    bool
+	Conditional        bool           // Send If-None-Match and If-Modified-Since based on the last result.
+	CheckInterval      string         // Minutes, a duration such as 15s, or a cron expression such as 5 2 * * *
+	TimeZone           string         // Time zone used for cron expressions, ex: America/Chicago
+	Jitter             time.Duration  // Random delay of up to this duration added to each scheduled check, ex: 10s
+	FailingInterval    time.Duration  // Shorter interval used while the Endpoint is failing, ex: 30s
+	RecoveryPasses     int            // Consecutive passing checks before the normal schedule resumes. Defaults to 1.
+	Timeout            time.Duration  // Maximum time for a single request attempt, ex: 30s
+	Retries            int            // Number of additional attempts when a request fails.
+	RetryBackoff       time.Duration  // Delay before the first retry, doubled for each subsequent retry.
+	TLS                *TLSConfig     // Overrides the application TLS settings.
+	Proxy              *ProxyConfig   // Overrides the application proxy settings.
+	Auth               *AuthConfig    // Overrides the application authentication provider.
+	Signing            *SigningConfig // Signs each request, ex: hmac or awsv4.
+	Maintenance        []MaintenanceConfig
+	Notifiers          []string
+	Validators         []string
 }
 
 // NotifierConfig represents the config data for a Notification channel.
@@ -91,28 +126,60 @@ type Application struct {
 	LastModified time.Time
 	cancel       chan (bool)
 	shutdown     bool
+	monitorWg    *sync.WaitGroup // Tracks the Feed Checker and its checks, so shutdown waits for them.
 	rwMu         *sync.RWMutex
+	pool         *checkPool
+	data         map[string]interface{} // Most recent result data for each Endpoint, used by dynamic Endpoint templates.
+	Maintenance  []*MaintenanceWindow   // Maintenance windows that apply to every Endpoint.
 	Endpoints    []*Endpoint
 }
 
 // Endpoint defines an endpoint (which can be dynamic) to check.
 type Endpoint struct {
-	Key               string
-	Name              string
-	URL               string
-	Method            string
-	RequestBody       string
-	Headers           map[string]string
-	Dynamic           bool
-	IgnoreRedirects   bool
-	CheckIntervalMin  int
-	Notifiers         []Notifier
-	Validators        []Validator
-	CurrentURLs       []string // Most recent parsed dynamic URLs
-	CurrentStatus     int
-	CurrentValidation []*ValidationResult
-	lastCheckTime     time.Time
-	nextCheckTime     time.Time
+	Key                string
+	Name               string
+	URL                string
+	Method             string
+	RequestBody        string
+	Headers            map[string]string
+	Dynamic            bool
+	DynamicConcurrency int
+	DependsOn          []string // Keys of the Endpoints whose data this Endpoint uses.
+	NotifyURLChanges   bool
+	StatusPolicy       string
+	StatusThreshold    int
+	Sample             int
+	SampleMode         string
+	CoverageWindow     time.Duration
+	IgnoreRedirects    bool
+	Conditional        bool
+	Schedule           *Schedule
+	Jitter             time.Duration
+	FailingInterval    time.Duration
+	RecoveryPasses     int
+	Failing            bool // The failing interval is in effect.
+	Passes             int  // Consecutive passing checks while the failing interval is in effect.
+	Timeout            time.Duration
+	Retries            int
+	RetryBackoff       time.Duration
+	Notifiers          []Notifier
+	Validators         []Validator
+	Authenticator      Authenticator
+	Signer             Signer
+	Maintenance        []*MaintenanceWindow
+	CurrentURLs        []string          // Most recent parsed dynamic URLs
+	CurrentLabels      map[string]string // Labels of the most recent dynamic URLs
+	CurrentStatus      int
+	CurrentValidation  []*ValidationResult
+	dynamicURLs        *dynamicURLGenerator
+	currentURLStatus   map[string]*URLStatus
+	urlFirstSeen       map[string]time.Time
+	dependents         []*Endpoint
+	transport          *http.Transport
+	checking           bool
+	checkResults       *[]*EndpointResult // Collects the results of an on-demand check.
+	lastCheckTime      time.Time
+	nextCheckTime      time.Time
 }
 
 // Notifier defines the interface that feed result notifiers need to implement.
@@ -132,19 +199,33 @@ type EndpointResult struct {
 	AppKey            string
 	EndpointKey       string
 	URL               string
+	Request           *RequestInfo
+	Redirects         []RedirectHop
+	FinalURL          string
 	CheckTime         time.Time
 	Duration          time.Duration
+	Timing            RequestTiming
 	Size              int64
 	Status            int
+	Attempts          []RequestAttempt // Each request made, the last of which produced the result.
 	Headers           map[string][]string
+	TLS               *TLSInfo
+	Proxy             string // Proxy used for the request, empty when connecting directly.
+	Maintenance       string // Name of the maintenance window the result was captured in.
 	Body              []byte `json:"-"`
 	BodyHash          string
 	ValidationResults []*ValidationResult
 	BodyChanged       bool
+	NotModified       bool // The server returned 304 for a conditional request, so the previous body was reused.
+	FetchError        *FetchError
+	stored            chan struct{} // Closed by the result writer once an on-demand result is stored.
 }
 
-// Valid returns true only if all the validation results are valid.
+// Valid returns true only if the request completed and all the validation results are valid.
 func (er *EndpointResult) Valid() bool {
+	if er.FetchError != nil {
+		return false
+	}
 	for _, vr := range er.ValidationResults {
 		if !vr.Valid {
 			return false
@@ -153,8 +234,17 @@ func (er *EndpointResult) Valid() bool {
 	return true
 }
 
+// Recovered returns true if the result is valid but only succeeded after one or more retries.
+func (er *EndpointResult) Recovered() bool {
+	return len(er.Attempts) > 1 && er.Valid()
+}
+
 // LoadBody loads the body of the result from storage.
 func (er *EndpointResult) LoadBody() error {
+	if er.BodyHash == "" {
+		return nil
+	}
+
 	r, err := GetGitRepo(er.AppKey, er.EndpointKey, er.URL)
 	if err != nil {
 		return err
@@ -175,6 +265,43 @@ type ValidationResult struct {
 	Errors []string
 }
 
+// TLSInfo describes the TLS connection used to retrieve an EndpointResult.
+type TLSInfo struct {
+	ServerName   string // The host name the certificate was verified against.
+	Certificates []CertificateInfo
+}
+
+// CertificateInfo describes a certificate in the chain presented by the server, starting with the leaf.
+type CertificateInfo struct {
+	Subject     string
+	Issuer      string
+	DNSNames    []string
+	IPAddresses []string
+	NotBefore   time.Time
+	NotAfter    time.Time
+}
+
+// RequestAttempt describes a single attempt to request an Endpoint.
+type RequestAttempt struct {
+	Status   int    // Status of the response, or 0 if the request failed.
+	Error    string // Error performing the request, if any.
+	Duration time.Duration
+}
+
+// RedirectHop describes a redirect response received while fetching an Endpoint.
+type RedirectHop struct {
+	URL      string
+	Status   int
+	Location string
+	Duration time.Duration
+}
+
+// FetchError describes a request that could not be completed, such as a DNS or connection failure.
+type FetchError struct {
+	Class   string
+	Message string
+}
+
 func loadConfigFile() *Configuration {
 
 	var c = &Configuration{}
@@ -199,6 +326,10 @@ func (c *Configuration) initialize() {
 	}
 
 	c.WebDevMode = options.WebDevelopment
+
+	if c.MaxConcurrentChecks <= 0 {
+		c.MaxConcurrentChecks = defaultMaxConcurrentChecks
+	}
 }
 
 func (c *Configuration) initializeNotifier(vtype string) (Notifier, bool) {
@@ -224,11 +355,68 @@ func (c *Configuration) initializeValidator(vtype string) (Validator, bool) {
 		return &ValidateStatus{}, true
 	case "Size":
 		return &ValidateSize{}, true
+	case "Certificate":
+		return &ValidateCertificate{}, true
+	case "Redirect":
+		return &ValidateRedirect{}, true
+	default:
+		return nil, false
+	}
+}
+
+func (c *Configuration) initializeAuthenticator(atype string) (Authenticator, bool) {
+	switch atype {
+	case "oauth2":
+		return &OAuth2Authenticator{}, true
+	default:
+		return nil, false
+	}
+}
+
+// loadAuthenticator creates and initializes the Authenticator defined by the config, or returns nil if none is defined.
+// Requests made by the Authenticator use the transport.
+func (c *Configuration) loadAuthenticator(ac *AuthConfig, transport *http.Transport) (Authenticator, error) {
+	if ac == nil {
+		return nil, nil
+	}
+	a, ok := c.initializeAuthenticator(ac.Type)
+	if !ok {
+		return nil, fmt.Errorf("unknown Authenticator type %v", ac.Type)
+	}
+	err := a.initialize(ac.Config, transport)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (c *Configuration) initializeSigner(stype string) (Signer, bool) {
+	switch stype {
+	case "hmac":
+		return &HMACSigner{}, true
+	case "awsv4":
+		return &AWSV4Signer{}, true
 	default:
 		return nil, false
 	}
 }
 
+// loadSigner creates and initializes the Signer defined by the config, or returns nil if none is defined.
+func (c *Configuration) loadSigner(sc *SigningConfig) (Signer, error) {
+	if sc == nil {
+		return nil, nil
+	}
+	s, ok := c.initializeSigner(sc.Type)
+	if !ok {
+		return nil, fmt.Errorf("unknown Signer type %v", sc.Type)
+	}
+	err := s.initialize(sc.Config)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
 func (c *Configuration) initializeApplications() {
 
 	path := filepath.Join(c.AppConfigDir, "*.yaml")
@@ -260,13 +448,27 @@ func (c *Configuration) initializeApplication(file string) *Application {
 		log.Errorf("Unable to load application configuration file: %v - %v", file, err)
 		return nil
 	}
+	b, short, err := expandConfigReferences(b)
+	if err != nil {
+		log.Errorf("Unable to load application configuration file: %v - %v", file, err)
+		return nil
+	}
+	for _, ref := range short {
+		log.Warnf("The value of %v in application configuration file %v is shorter than %d characters, so it is only redacted where it is a whole value such as a header or query parameter.", ref, file, minSecretLength)
+	}
 	err = yaml.UnmarshalStrict(b, a)
 	if err != nil {
 		log.Errorf("Unable to parse application configuration file: %v - %v", file, err)
 		return nil
 	}
 
-	app := &Application{Key: a.Key, Name: a.Name, FileName: file, cancel: make(chan bool), rwMu: &sync.RWMutex{}}
+	app := &Application{Key: a.Key, Name: a.Name, FileName: file, cancel: make(chan bool), rwMu: &sync.RWMutex{}, data: make(map[string]interface{})}
+
+	concurrentChecks := defaultAppConcurrentChecks
+	if a.MaxConcurrentChecks > 0 {
+		concurrentChecks = a.MaxConcurrentChecks
+	}
+	app.pool = newCheckPool(concurrentChecks)
 
 	stat, err := os.Stat(file)
 	if err == nil {
@@ -305,6 +507,20 @@ func (c *Configuration) initializeApplication(file string) *Application {
 		}
 	}
 
+	// Create the transport shared by Endpoints that do not override the TLS or proxy settings.
+	transport, err := newTransport(a.TLS, a.Proxy)
+	if err != nil {
+		log.Errorf("Invalid TLS or proxy configuration for app %v. %v", a.Name, err)
+		return nil
+	}
+
+	// Create the authenticator shared by Endpoints that do not define their own.
+	authenticator, err := c.loadAuthenticator(a.Auth, transport)
+	if err != nil {
+		log.Errorf("Invalid authentication configuration for app %v. %v", a.Name, err)
+		return nil
+	}
+
 	// Create and initialize all the endpoints.
 	eps := make([]*Endpoint, len(a.Endpoints))
 	for i, e := range a.Endpoints {
@@ -314,30 +530,136 @@ func (c *Configuration) initializeApplication(file string) *Application {
 			method = e.Method
 		}
 
+		timeout := defaultTimeout
+		if e.Timeout > 0 {
+			timeout = e.Timeout
+		}
+
+		if err := validateRetries(e.Retries); err != nil {
+			log.Errorf("Invalid retries for Endpoint %v (%v) in app %v. %v", e.Name, e.Key, a.Name, err)
+			return nil
+		}
+
+		retryBackoff := defaultRetryBackoff
+		if e.RetryBackoff > 0 {
+			retryBackoff = e.RetryBackoff
+		}
+
+		dynamicConcurrency := defaultDynamicConcurrency
+		if e.DynamicConcurrency > 0 {
+			dynamicConcurrency = e.DynamicConcurrency
+		}
+
+		statusPolicy, err := validateStatusPolicy(e.StatusPolicy, e.StatusThreshold)
+		if err != nil {
+			log.Errorf("Invalid status policy for Endpoint %v (%v) in app %v. %v", e.Name, e.Key, a.Name, err)
+			return nil
+		}
+
+		sampleMode, err := validateSampleMode(e.SampleMode)
+		if err != nil {
+			log.Errorf("Invalid sampling configuration for Endpoint %v (%v) in app %v. %v", e.Name, e.Key, a.Name, err)
+			return nil
+		}
+
+		schedule, err := parseSchedule(e.CheckInterval, e.TimeZone)
+		if err != nil {
+			log.Errorf("Invalid checkinterval for Endpoint %v (%v) in app %v. %v", e.Name, e.Key, a.Name, err)
+			return nil
+		}
+		if e.Jitter < 0 || (schedule.Interval > 0 && e.Jitter >= schedule.Interval) {
+			log.Errorf("Invalid jitter for Endpoint %v (%v) in app %v. The jitter must be less than the check interval.", e.Name, e.Key, a.Name)
+			return nil
+		}
+		schedule.spread(a.Key + "/" + e.Key)
+
+		recoveryPasses, err := validateFailingInterval(e.FailingInterval, e.RecoveryPasses, schedule)
+		if err != nil {
+			log.Errorf("Invalid failinginterval for Endpoint %v (%v) in app %v. %v", e.Name, e.Key, a.Name, err)
+			return nil
+		}
+
 		ep := &Endpoint{
-			Key:              e.Key,
-			Name:             e.Name,
-			URL:              e.URL,
-			Method:           method,
-			RequestBody:      e.RequestBody,
-			Headers:          e.Headers,
-			Dynamic:          e.Dynamic,
-			IgnoreRedirects:  e.IgnoreRedirects,
-			CheckIntervalMin: e.CheckInterval,
-			lastCheckTime:    time.Unix(0, 0),
-			nextCheckTime:    time.Now(),
-		}
-
-		if !ep.Dynamic {
-			epr, _ := GetLastEndpointResult(a.Key, e.Key, e.URL)
-			if epr != nil {
-				if epr.CheckTime.Add(time.Duration(ep.CheckIntervalMin) * time.Minute).After(time.Now()) {
-					ep.lastCheckTime = epr.CheckTime
-					ep.nextCheckTime = epr.CheckTime.Add(time.Duration(ep.CheckIntervalMin) * time.Minute)
-				}
+			Key:                e.Key,
+			Name:               e.Name,
+			URL:                e.URL,
+			Method:             method,
+			RequestBody:        e.RequestBody,
+			Headers:            e.Headers,
+			Dynamic:            e.Dynamic,
+			DynamicConcurrency: dynamicConcurrency,
+			DependsOn:          e.DependsOn,
+			NotifyURLChanges:   e.NotifyURLChanges,
+			StatusPolicy:       statusPolicy,
+			StatusThreshold:    e.StatusThreshold,
+			Sample:             e.Sample,
+			SampleMode:         sampleMode,
+			CoverageWindow:     e.CoverageWindow,
+			IgnoreRedirects:    e.IgnoreRedirects,
+			Conditional:        e.Conditional,
+			Schedule:           schedule,
+			Jitter:             e.Jitter,
+			FailingInterval:    e.FailingInterval,
+			RecoveryPasses:     recoveryPasses,
+			Timeout:            timeout,
+			Retries:            e.Retries,
+			RetryBackoff:       retryBackoff,
+			Authenticator:      authenticator,
+			transport:          transport,
+			currentURLStatus:   make(map[string]*URLStatus),
+			CurrentLabels:      make(map[string]string),
+			urlFirstSeen:       make(map[string]time.Time),
+		}
+
+		if e.TLS != nil || e.Proxy != nil {
+			ep.transport, err = newTransport(a.TLS.merge(e.TLS), a.Proxy.merge(e.Proxy))
+			if err != nil {
+				log.Errorf("Invalid TLS or proxy configuration for Endpoint %v (%v) in app %v. %v", e.Name, e.Key, a.Name, err)
+				return nil
+			}
+		}
+
+		if e.Auth != nil {
+			ep.Authenticator, err = c.loadAuthenticator(e.Auth, ep.transport)
+			if err != nil {
+				log.Errorf("Invalid authentication configuration for Endpoint %v (%v) in app %v. %v", e.Name, e.Key, a.Name, err)
+				return nil
+			}
+		}
+
+		if e.DynamicURLs != nil {
+			ep.dynamicURLs, err = newDynamicURLGenerator(e.DynamicURLs)
+			if err != nil {
+				log.Errorf("Invalid dynamicurls configuration for Endpoint %v (%v) in app %v. %v", e.Name, e.Key, a.Name, err)
+				return nil
+			}
+			ep.Dynamic = true
+			if ep.URL == "" {
+				ep.URL = e.DynamicURLs.URL
 			}
 		}
 
+		for _, m := range e.Maintenance {
+			m.Endpoint = e.Key
+			w, err := newMaintenanceWindow(m)
+			if err != nil {
+				log.Errorf("Invalid maintenance window %v for Endpoint %v (%v) in app %v. %v", m.Name, e.Name, e.Key, a.Name, err)
+				return nil
+			}
+			ep.Maintenance = append(ep.Maintenance, w)
+		}
+
+		ep.Signer, err = c.loadSigner(e.Signing)
+		if err != nil {
+			log.Errorf("Invalid signing configuration for Endpoint %v (%v) in app %v. %v", e.Name, e.Key, a.Name, err)
+			return nil
+		}
+
+		// The first check is spread across the interval by the phase of the schedule. A check that was missed while
+		// stopped is made up soon after starting.
+		ep.lastCheckTime = app.restoreLastCheck(ep)
+		ep.nextCheckTime = ep.Schedule.firstCheck(ep.lastCheckTime, time.Now()).Add(jitter(ep.Jitter))
+
 		n := make([]Notifier, len(e.Notifiers)+len(defaultNotifiers))
 		// Add Default Notifiers
 		copy(n, defaultNotifiers)
@@ -370,8 +692,27 @@ func (c *Configuration) initializeApplication(file string) *Application {
 
 		eps[i] = ep
 	}
+
+	err = resolveDependencies(eps)
+	if err != nil {
+		log.Errorf("Invalid endpoint dependencies in app %v. %v", a.Name, err)
+		return nil
+	}
 	app.Endpoints = eps
 
+	// Application maintenance windows apply to every Endpoint, unless they name a single Endpoint.
+	for _, m := range a.Maintenance {
+		w, err := newMaintenanceWindow(m)
+		if err == nil {
+			err = app.addMaintenance(w)
+		}
+		if err != nil {
+			log.Errorf("Invalid maintenance window %v in app %v. %v", m.Name, a.Name, err)
+			return nil
+		}
+	}
+	app.loadMaintenance()
+
 	return app
 }
 
@@ -406,13 +747,17 @@ func (a *Application) startFeedMonitor(wg *sync.WaitGroup) {
 
 	ticker := time.NewTicker(1 * time.Second)
 
+	a.rwMu.Lock()
+	a.monitorWg = wg
+	a.rwMu.Unlock()
+
+	wg.Add(1)
 	go func() {
 		log.Debug("Started Feed Checker.")
 
-		wg.Add(1)
 		defer wg.Done()
+		defer ticker.Stop()
 
-		var data = make(map[string]interface{})
 		for {
 			select {
 			case <-ticker.C:
@@ -424,21 +769,9 @@ func (a *Application) startFeedMonitor(wg *sync.WaitGroup) {
 						log.Debug("Shutting down Feed Checker.")
 						return
 					}
-					if e.shouldCheckNow() {
-						e.scheduleNextCheck()
-						a.rwMu.Lock()
-						if e.Dynamic {
-							urls, err := e.parseURLs(data)
-							if err != nil {
-								log.Errorf("Error parsing URL: %v Error: %v", e.URL, err.Error())
-							}
-							for _, url := range urls {
-								data[e.Key], _ = fetchEndpoint(a, e, url, data)
-							}
-						} else {
-							data[e.Key], _ = fetchEndpoint(a, e, e.URL, data)
-						}
-						a.rwMu.Unlock()
+					if a.startCheck(e) {
+						wg.Add(1)
+						go a.runCheck(wg, e)
 					}
 				}
 			case <-a.cancel:
@@ -449,6 +782,263 @@ func (a *Application) startFeedMonitor(wg *sync.WaitGroup) {
 	}()
 }
 
+// startCheck schedules the next check and marks the Endpoint as checking if it is due, not already being checked, not
+// in a maintenance window that skips checks, and the Endpoints it depends on have data.
+func (a *Application) startCheck(e *Endpoint) bool {
+	a.rwMu.Lock()
+	defer a.rwMu.Unlock()
+	if e.checking || !e.shouldCheckNow() || !a.dependenciesReady(e) {
+		return false
+	}
+	if w := a.activeMaintenance(e, time.Now()); w != nil && w.Mode == MaintenanceModeSkip {
+		e.nextCheckTime = e.Schedule.next(time.Now())
+		return false
+	}
+	e.scheduleNextCheck()
+	e.checking = true
+	return true
+}
+
+// startOnDemandCheck marks the Endpoint as checking regardless of its schedule or maintenance windows, and collects
+// the results of the check into results if it is not nil. The check is added to the WaitGroup of the Feed Checker,
+// which is returned for runCheck. An error is returned if the application is not being monitored, the Endpoint is
+// already being checked or its dependencies have no data yet.
+func (a *Application) startOnDemandCheck(e *Endpoint, results *[]*EndpointResult) (*sync.WaitGroup, error) {
+	a.rwMu.Lock()
+	defer a.rwMu.Unlock()
+	if a.shutdown || a.monitorWg == nil {
+		return nil, fmt.Errorf("%v is not being monitored", a.Name)
+	}
+	if e.checking {
+		return nil, fmt.Errorf("%v is already being checked", e.Name)
+	}
+	if !a.dependenciesReady(e) {
+		return nil, fmt.Errorf("the endpoints %v depends on have not been checked yet", e.Name)
+	}
+	e.scheduleNextCheck()
+	e.checking = true
+	e.checkResults = results
+	a.monitorWg.Add(1)
+	return a.monitorWg, nil
+}
+
+// runCheck waits for a slot in the application and global pools, then checks the Endpoint.
+func (a *Application) runCheck(wg *sync.WaitGroup, e *Endpoint) {
+	defer wg.Done()
+	defer func() {
+		a.rwMu.Lock()
+		e.checking = false
+		e.checkResults = nil
+		a.rwMu.Unlock()
+	}()
+
+	if !a.pool.acquire(a.cancel) {
+		return
+	}
+	defer a.pool.release()
+
+	if !globalCheckPool.acquire(a.cancel) {
+		return
+	}
+	defer globalCheckPool.release()
+
+	a.checkEndpoint(e)
+
+	a.rwMu.Lock()
+	e.updateScheduleMode()
+	a.rwMu.Unlock()
+
+	a.refreshDependents(e)
+}
+
+// checkEndpoint fetches the Endpoint, or each of its dynamic URLs, and publishes the result data for other Endpoints to use.
+func (a *Application) checkEndpoint(e *Endpoint) {
+	log := log.WithFields(logrus.Fields{"module": "feedmonitor", "app": a.Key, "endpoint": e.Key})
+
+	data := a.getData()
+	if e.Dynamic {
+		urls, labels, err := e.parseURLs(data)
+		if err != nil {
+			log.Errorf("Error parsing URL: %v Error: %v", e.URL, err.Error())
+			// There is no URL to fetch, so the failure is recorded against the URL template of the Endpoint.
+			epr := &EndpointResult{AppKey: a.Key, EndpointKey: e.Key, URL: e.URL, CheckTime: time.Now()}
+			a.rwMu.RLock()
+			if w := a.activeMaintenance(e, epr.CheckTime); w != nil {
+				epr.Maintenance = w.Name
+			}
+			a.rwMu.RUnlock()
+			publishFetchError(a, e, epr, "Template", FetchErrorTemplate, fmt.Errorf("URL: %v", err))
+			// The dependents are checked without data, as they are after a failed static check, so they record
+			// their own failures instead of waiting for data that never arrives.
+			a.setData(e.Key, nil)
+			return
+		}
+		// A template that produces no URLs retires every URL and clears their statuses, so the Endpoint has an unknown
+		// status and empty result data until URLs appear again.
+		a.rwMu.Lock()
+		e.CurrentValidation = nil
+		e.CurrentURLs = urls
+		e.CurrentLabels = labels
+		e.pruneURLStatus()
+		e.pruneURLFirstSeen()
+		sample, overdue := e.sampleURLs(urls, time.Now())
+		a.rwMu.Unlock()
+		a.trackURLs(e, urls, labels)
+		if overdue > len(sample) {
+			log.Warnf("%d URLs are outside the coverage window of %v, which is more than the sample of %d. The oldest are checked and the rest stay overdue.", overdue, e.CoverageWindow, e.Sample)
+		}
+
+		results := a.fetchDynamicURLs(e, sample, data)
+		if len(sample) < len(urls) {
+			log.Infof("Checked a sample of %d of %d URLs.", len(sample), len(urls))
+			results = sampledResultData(urls, sample, results, data[e.Key])
+		}
+		a.setData(e.Key, dynamicResultData(urls, results))
+	} else {
+		res, _ := fetchEndpoint(a, e, e.URL, data)
+		a.setData(e.Key, res)
+	}
+}
+
+// trackURLs records the lifecycle of the dynamic URLs and notifies when URLs appear or disappear, if enabled.
+func (a *Application) trackURLs(e *Endpoint, urls []string, labels map[string]string) {
+	added, removed, err := UpdateDynamicURLs(a.Key, e.Key, urls, labels, time.Now())
+	if err != nil {
+		return
+	}
+	if len(added) > 0 || len(removed) > 0 {
+		log.WithFields(logrus.Fields{"module": "feedmonitor", "app": a.Key, "endpoint": e.Key}).Infof("Dynamic URLs changed, %d added and %d removed.", len(added), len(removed))
+		a.rwMu.RLock()
+		maintenance := a.activeMaintenance(e, time.Now())
+		a.rwMu.RUnlock()
+		if e.NotifyURLChanges && maintenance == nil {
+			NotificationChannel <- &Notification{Application: a, Endpoint: e, URLChanges: &URLChanges{Added: added, Removed: removed}}
+		}
+	}
+}
+
+// fetchDynamicURLs fetches the URLs concurrently, limited by the DynamicConcurrency of the Endpoint and by the check
+// pools. The first request uses the slots the check already holds, and each additional concurrent request takes a
+// slot in the application and global pools, so maxconcurrentchecks limits the requests in flight. Additional slots are
+// only taken when they are free, so a check never waits for slots while holding others.
+// The result data is returned in the same order as the URLs, with nil for URLs that could not be fetched.
+func (a *Application) fetchDynamicURLs(e *Endpoint, urls []string, data map[string]interface{}) []map[string]interface{} {
+	results := make([]map[string]interface{}, len(urls))
+	done := make(chan bool, len(urls)) // Reports whether each finished request held additional slots.
+	held := true                       // The slots held by the check are free.
+	running := 0
+	var wg sync.WaitGroup
+
+	for i, url := range urls {
+		extra := false
+		for {
+			if running < e.DynamicConcurrency {
+				if held {
+					held = false
+					break
+				}
+				if a.tryAcquireSlots() {
+					extra = true
+					break
+				}
+			}
+			select {
+			case x := <-done:
+				running--
+				if !x {
+					held = true
+				}
+			case <-a.cancel:
+				wg.Wait()
+				return results
+			}
+		}
+
+		running++
+		wg.Add(1)
+		go func(i int, url string, extra bool) {
+			defer wg.Done()
+			results[i], _ = fetchEndpoint(a, e, url, data)
+			if extra {
+				a.releaseSlots()
+			}
+			done <- extra
+		}(i, url, extra)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// dynamicResultData builds the result data for a dynamic Endpoint. byURL maps each URL to its result data and list
+// contains the result data in URL order, each with the url added. The data and headers of the last URL are also kept
+// at the top level for templates that predate per URL results.
+func dynamicResultData(urls []string, results []map[string]interface{}) map[string]interface{} {
+	byURL := make(map[string]interface{})
+	list := make([]interface{}, 0, len(urls))
+	for i, url := range urls {
+		if results[i] == nil {
+			continue
+		}
+		entry := map[string]interface{}{"url": url}
+		for k, v := range results[i] {
+			entry[k] = v
+		}
+		byURL[url] = entry
+		list = append(list, entry)
+	}
+
+	resultData := map[string]interface{}{"byURL": byURL, "list": list}
+	if len(results) > 0 {
+		for k, v := range results[len(results)-1] {
+			resultData[k] = v
+		}
+	}
+	return resultData
+}
+
+// sampledResultData returns the result data for every URL, using the previous result data for URLs that were not
+// part of the sample.
+func sampledResultData(urls []string, sample []string, results []map[string]interface{}, prev interface{}) []map[string]interface{} {
+	sampled := make(map[string]map[string]interface{}, len(sample))
+	for i, url := range sample {
+		sampled[url] = results[i]
+	}
+
+	var prevByURL map[string]interface{}
+	if p, ok := prev.(map[string]interface{}); ok {
+		prevByURL, _ = p["byURL"].(map[string]interface{})
+	}
+
+	all := make([]map[string]interface{}, len(urls))
+	for i, url := range urls {
+		if r, ok := sampled[url]; ok {
+			all[i] = r
+		} else if r, ok := prevByURL[url].(map[string]interface{}); ok {
+			all[i] = r
+		}
+	}
+	return all
+}
+
+// getData returns a copy of the most recent result data for each Endpoint.
+func (a *Application) getData() map[string]interface{} {
+	a.rwMu.RLock()
+	defer a.rwMu.RUnlock()
+	data := make(map[string]interface{}, len(a.data))
+	for k, v := range a.data {
+		data[k] = v
+	}
+	return data
+}
+
+// setData publishes the result data for an Endpoint.
+func (a *Application) setData(key string, value map[string]interface{}) {
+	a.rwMu.Lock()
+	a.data[key] = value
+	a.rwMu.Unlock()
+}
+
 func (a *Application) stopFeedMonitor() {
 	a.rwMu.Lock()
 	a.shutdown = true
@@ -456,37 +1046,65 @@ func (a *Application) stopFeedMonitor() {
 	a.rwMu.Unlock()
 }
 
+// scheduleNextCheck records the start of a check and schedules the next one. The schedule is aligned to fixed times,
+// so neither the jitter nor a late check shifts the cadence.
 func (e *Endpoint) scheduleNextCheck() {
-	if e.lastCheckTime.Unix() == 0 {
-		e.lastCheckTime = time.Now()
-		e.nextCheckTime = e.lastCheckTime.Add(time.Duration(e.CheckIntervalMin) * time.Minute)
-	} else {
-		e.lastCheckTime = e.nextCheckTime
-		e.nextCheckTime = e.lastCheckTime.Add(time.Duration(e.CheckIntervalMin) * time.Minute)
-		// Make sure we are not getting backed up.
-		if e.nextCheckTime.Before(time.Now()) {
-			e.nextCheckTime = time.Now()
+	e.lastCheckTime = time.Now()
+	if e.Failing {
+		e.nextCheckTime = e.lastCheckTime.Add(e.FailingInterval)
+		return
+	}
+	e.nextCheckTime = e.Schedule.next(e.lastCheckTime).Add(jitter(e.Jitter))
+}
+
+// UpcomingChecks returns the next n scheduled check times, in the time zone of the schedule.
+func (e *Endpoint) UpcomingChecks(n int) []time.Time {
+	next := e.nextCheckTime
+	if now := time.Now(); next.Before(now) {
+		next = now
+	}
+	if e.Failing {
+		times := make([]time.Time, n)
+		for i := range times {
+			times[i] = next.Add(time.Duration(i) * e.FailingInterval).In(e.Schedule.Location)
 		}
+		return times
 	}
+	return e.Schedule.upcoming(next, n)
 }
 
 func (e *Endpoint) shouldCheckNow() bool {
 	return e.nextCheckTime.Before(time.Now())
 }
 
-func (e *Endpoint) parseURLs(data interface{}) ([]string, error) {
+// parseURLs returns the dynamic URLs for the Endpoint and the label to display for each URL, if any.
+func (e *Endpoint) parseURLs(data map[string]interface{}) ([]string, map[string]string, error) {
 	log := log.WithField("endpoint", e.Name)
 
-	t := template.New("URL Template")
+	if e.dynamicURLs != nil {
+		urls, labels, err := e.dynamicURLs.generate(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(urls) == 0 {
+			log.Warnf("Dynamic Endpoint %s did not produce any URLs to query.", e.URL)
+		}
+		for _, v := range urls {
+			log.Infof("Parsed Dynamic URL: %v (%v)", v, labels[v])
+		}
+		return urls, labels, nil
+	}
+
+	t := template.New("URL Template").Funcs(templateFuncs())
 	t, err := t.Parse(e.URL)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	buf := new(bytes.Buffer)
 	err = t.Execute(buf, data)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	templateResult := buf.String()
@@ -496,7 +1114,7 @@ func (e *Endpoint) parseURLs(data interface{}) ([]string, error) {
 
 	if len(strings.TrimSpace(templateResult)) == 0 {
 		log.Warnf("Dynamic Endpoint %s did not produce any URLs to query.", e.URL)
-		return nil, nil
+		return nil, nil, nil
 	}
 
 	urls := strings.Split(templateResult, urlSeparator)
@@ -504,7 +1122,13 @@ func (e *Endpoint) parseURLs(data interface{}) ([]string, error) {
 		log.Infof("Parsed Dynamic URL: %v", v)
 	}
 
-	e.CurrentURLs = urls
+	return urls, nil, nil
+}
 
-	return urls, nil
+// URLLabel returns the label of a dynamic URL, or the URL itself if it does not have a label.
+func (e *Endpoint) URLLabel(url string) string {
+	if label, ok := e.CurrentLabels[url]; ok {
+		return label
+	}
+	return url
 }
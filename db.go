@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"strconv"
 	"sync"
 	"time"
 
@@ -16,6 +17,8 @@ import (
 
 const bucketPerformanceLog = "PerformanceLog"
 const bucketEndpointResults = "EndpointResults"
+const bucketDynamicURLs = "DynamicURLs"
+const bucketMaintenance = "Maintenance"
 
 var db *bolt.DB
 var dbLog *logrus.Entry
@@ -24,6 +27,7 @@ var dbLog *logrus.Entry
 type PerformanceEntry struct {
 	Duration int64
 	Size     int64
+	Timing   RequestTiming
 }
 
 // PerformanceEntryResult represents the value of a performance log entry including the time key.
@@ -32,6 +36,16 @@ type PerformanceEntryResult struct {
 	PerformanceEntry
 }
 
+// DynamicURL records the lifecycle of a URL produced by a dynamic Endpoint.
+type DynamicURL struct {
+	URL         string
+	Label       string
+	FirstSeen   time.Time
+	LastSeen    time.Time
+	Retired     bool
+	RetiredTime time.Time
+}
+
 // StartDatabase initializes the datastore
 func StartDatabase(filePath string, logrus *logrus.Entry) error {
 
@@ -63,9 +77,14 @@ func StartResultWriter(ctx context.Context, wg *sync.WaitGroup) chan *EndpointRe
 		for {
 			select {
 			case res := <-c:
-				saveResultBody(res)
-				log.Debugf("Saved Body, hash: %v", res.BodyHash)
+				if res.FetchError == nil && !res.NotModified {
+					saveResultBody(res)
+					log.Debugf("Saved Body, hash: %v", res.BodyHash)
+				}
 				recordResult(res)
+				if res.stored != nil {
+					close(res.stored)
+				}
 			case <-ctx.Done():
 				log.Debug("Shutting down Result Writer.")
 				return
@@ -94,8 +113,11 @@ func saveResultBody(er *EndpointResult) {
 }
 
 func recordResult(e *EndpointResult) {
-	entry := PerformanceEntry{Duration: e.Duration.Nanoseconds() / int64(time.Millisecond), Size: e.Size}
-	WritePerformanceRecord(e, entry)
+	// Results without a response would skew the performance log, so they are only stored as results.
+	if e.FetchError == nil {
+		entry := PerformanceEntry{Duration: e.Duration.Nanoseconds() / int64(time.Millisecond), Size: e.Size, Timing: e.Timing}
+		WritePerformanceRecord(e, entry)
+	}
 	WriteEndpointResult(e)
 }
 
@@ -449,6 +471,210 @@ func GetEndpointResultsForDate(appKey string, endpointKey string, url string, da
 	return entries, err
 }
 
+// UpdateDynamicURLs records the current URLs of a dynamic Endpoint. It returns the URLs that appeared since the last
+// update, including retired URLs that returned, and the URLs that were retired by this update. No URLs are reported
+// as added the first time an Endpoint is updated.
+func UpdateDynamicURLs(appKey string, endpointKey string, urls []string, labels map[string]string, t time.Time) (added []DynamicURL, removed []DynamicURL, err error) {
+	err = db.Update(func(tx *bolt.Tx) error {
+
+		b, err := tx.CreateBucketIfNotExists([]byte(bucketDynamicURLs))
+		if err != nil {
+			return err
+		}
+		appb, err := b.CreateBucketIfNotExists([]byte(appKey))
+		if err != nil {
+			return err
+		}
+		epb, err := appb.CreateBucketIfNotExists([]byte(endpointKey))
+		if err != nil {
+			return err
+		}
+
+		first, _ := epb.Cursor().First()
+		firstUpdate := first == nil
+		current := make(map[string]bool, len(urls))
+
+		for _, url := range urls {
+			current[url] = true
+
+			var du DynamicURL
+			appeared := false
+			v := epb.Get([]byte(url))
+			if v == nil {
+				du = DynamicURL{URL: url, FirstSeen: t}
+				appeared = !firstUpdate
+			} else {
+				err = json.Unmarshal(v, &du)
+				if err != nil {
+					return err
+				}
+				appeared = du.Retired
+				du.Retired = false
+				du.RetiredTime = time.Time{}
+			}
+			du.LastSeen = t
+			if label, ok := labels[url]; ok {
+				du.Label = label
+			}
+			if appeared {
+				added = append(added, du)
+			}
+
+			err = putDynamicURL(epb, du)
+			if err != nil {
+				return err
+			}
+		}
+
+		c := epb.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if current[string(k)] {
+				continue
+			}
+
+			var du DynamicURL
+			err = json.Unmarshal(v, &du)
+			if err != nil {
+				return err
+			}
+			if du.Retired {
+				continue
+			}
+			du.Retired = true
+			du.RetiredTime = t
+			removed = append(removed, du)
+		}
+
+		// The retired URLs are written after iterating, since changing the bucket invalidates the cursor.
+		for _, du := range removed {
+			err = putDynamicURL(epb, du)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		dbLog.Errorf("Error updating dynamic URLs for App: %v, Endpoint: %v - %v", appKey, endpointKey, err.Error())
+	}
+	return added, removed, err
+}
+
+func putDynamicURL(b *bolt.Bucket, du DynamicURL) error {
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(du)
+	return b.Put([]byte(du.URL), buf.Bytes())
+}
+
+// GetDynamicURLs returns all the URLs that have been produced by a dynamic Endpoint, ordered by URL.
+func GetDynamicURLs(appKey string, endpointKey string) ([]DynamicURL, error) {
+	var urls []DynamicURL
+	err := db.View(func(tx *bolt.Tx) error {
+
+		b := tx.Bucket([]byte(bucketDynamicURLs))
+		if b == nil {
+			return nil
+		}
+		appb := b.Bucket([]byte(appKey))
+		if appb == nil {
+			return nil
+		}
+		epb := appb.Bucket([]byte(endpointKey))
+		if epb == nil {
+			return nil
+		}
+
+		return epb.ForEach(func(k, v []byte) error {
+			var du DynamicURL
+			err := json.Unmarshal(v, &du)
+			if err != nil {
+				return err
+			}
+			urls = append(urls, du)
+			return nil
+		})
+	})
+	return urls, err
+}
+
+// PutMaintenanceWindow stores a maintenance window created in the web interface, assigning it an ID if it does not
+// have one.
+func PutMaintenanceWindow(appKey string, c *MaintenanceConfig) error {
+	err := db.Update(func(tx *bolt.Tx) error {
+
+		b, err := tx.CreateBucketIfNotExists([]byte(bucketMaintenance))
+		if err != nil {
+			return err
+		}
+		appb, err := b.CreateBucketIfNotExists([]byte(appKey))
+		if err != nil {
+			return err
+		}
+
+		if c.ID == "" {
+			id, err := appb.NextSequence()
+			if err != nil {
+				return err
+			}
+			c.ID = strconv.FormatUint(id, 10)
+		}
+
+		var buf bytes.Buffer
+		json.NewEncoder(&buf).Encode(c)
+		return appb.Put([]byte(c.ID), buf.Bytes())
+	})
+
+	if err != nil {
+		dbLog.Errorf("Error storing maintenance window for App: %v - %v", appKey, err.Error())
+	}
+	return err
+}
+
+// DeleteMaintenanceWindow removes a maintenance window created in the web interface.
+func DeleteMaintenanceWindow(appKey string, id string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+
+		b := tx.Bucket([]byte(bucketMaintenance))
+		if b == nil {
+			return nil
+		}
+		appb := b.Bucket([]byte(appKey))
+		if appb == nil {
+			return nil
+		}
+		return appb.Delete([]byte(id))
+	})
+}
+
+// GetMaintenanceWindows returns the maintenance windows created in the web interface for an application.
+func GetMaintenanceWindows(appKey string) ([]MaintenanceConfig, error) {
+	var windows []MaintenanceConfig
+	err := db.View(func(tx *bolt.Tx) error {
+
+		b := tx.Bucket([]byte(bucketMaintenance))
+		if b == nil {
+			return nil
+		}
+		appb := b.Bucket([]byte(appKey))
+		if appb == nil {
+			return nil
+		}
+
+		return appb.ForEach(func(k, v []byte) error {
+			var c MaintenanceConfig
+			err := json.Unmarshal(v, &c)
+			if err != nil {
+				return err
+			}
+			windows = append(windows, c)
+			return nil
+		})
+	})
+	return windows, err
+}
+
 func getBucket(tx *bolt.Tx, bucketType string, appKey string, endpointKey string, url string) *bolt.Bucket {
 
 	b := tx.Bucket([]byte(bucketType))
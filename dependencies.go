@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template/parse"
+	"time"
+)
+
+// resolveDependencies adds the Endpoints referenced by the templates of each Endpoint to its DependsOn keys, links
+// each Endpoint to its dependents, and returns an error if a dependency is unknown or the dependencies contain a cycle.
+func resolveDependencies(eps []*Endpoint) error {
+	byKey := make(map[string]*Endpoint, len(eps))
+	for _, e := range eps {
+		byKey[e.Key] = e
+	}
+
+	for _, e := range eps {
+		keys := make(map[string]bool)
+		for _, k := range e.DependsOn {
+			if _, ok := byKey[k]; !ok {
+				return fmt.Errorf("endpoint %v depends on unknown endpoint %v", e.Key, k)
+			}
+			keys[k] = true
+		}
+		for _, k := range e.templateReferences() {
+			if _, ok := byKey[k]; ok && k != e.Key {
+				keys[k] = true
+			}
+		}
+
+		e.DependsOn = make([]string, 0, len(keys))
+		for k := range keys {
+			e.DependsOn = append(e.DependsOn, k)
+		}
+		sort.Strings(e.DependsOn)
+	}
+
+	for _, e := range eps {
+		for _, k := range e.DependsOn {
+			byKey[k].dependents = append(byKey[k].dependents, e)
+		}
+	}
+
+	// Depth first search, tracking the Endpoints on the current path to find cycles.
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(eps))
+	var path []string
+	var visit func(e *Endpoint) error
+	visit = func(e *Endpoint) error {
+		switch state[e.Key] {
+		case visiting:
+			return fmt.Errorf("dependency cycle: %v -> %v", strings.Join(path, " -> "), e.Key)
+		case visited:
+			return nil
+		}
+		state[e.Key] = visiting
+		path = append(path, e.Key)
+		for _, k := range e.DependsOn {
+			if err := visit(byKey[k]); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[e.Key] = visited
+		return nil
+	}
+	for _, e := range eps {
+		if err := visit(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// templateReferences returns the top level data keys referenced by the templates of the Endpoint.
+func (e *Endpoint) templateReferences() []string {
+	var refs []string
+	if e.dynamicURLs != nil {
+		refs = append(refs, e.dynamicURLs.source)
+	} else if e.Dynamic {
+		refs = append(refs, templateFields(e.URL)...)
+	}
+	refs = append(refs, templateFields(e.RequestBody)...)
+	for k, v := range e.Headers {
+		refs = append(refs, templateFields(k)...)
+		refs = append(refs, templateFields(v)...)
+	}
+	return refs
+}
+
+// templateFields returns the fields referenced on the root data of a template, such as mainfeed in
+// {{range .mainfeed.data}} or {{$.mainfeed.data}}. Templates that do not parse have no references.
+func templateFields(text string) []string {
+	if !strings.Contains(text, "{{") {
+		return nil
+	}
+
+	t := parse.New("references")
+	t.Mode = parse.SkipFuncCheck
+	_, err := t.Parse(text, "", "", make(map[string]*parse.Tree))
+	if err != nil {
+		return nil
+	}
+
+	var fields []string
+	var walk func(node parse.Node, root bool)
+	walk = func(node parse.Node, root bool) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Nodes {
+				walk(c, root)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe, root)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, c := range n.Cmds {
+				walk(c, root)
+			}
+		case *parse.CommandNode:
+			for _, c := range n.Args {
+				walk(c, root)
+			}
+		case *parse.ChainNode:
+			walk(n.Node, root)
+		case *parse.FieldNode:
+			if root {
+				fields = append(fields, n.Ident[0])
+			}
+		case *parse.VariableNode:
+			if n.Ident[0] == "$" && len(n.Ident) > 1 {
+				fields = append(fields, n.Ident[1])
+			}
+		case *parse.IfNode:
+			walk(n.Pipe, root)
+			walk(n.List, root)
+			walk(n.ElseList, root)
+		case *parse.RangeNode:
+			// The dot is the element inside the range, so only $ refers to the root data.
+			walk(n.Pipe, root)
+			walk(n.List, false)
+			walk(n.ElseList, root)
+		case *parse.WithNode:
+			walk(n.Pipe, root)
+			walk(n.List, false)
+			walk(n.ElseList, root)
+		case *parse.TemplateNode:
+			walk(n.Pipe, root)
+		}
+	}
+	walk(t.Root, true)
+	return fields
+}
+
+// Dependents returns the Endpoints that depend on the data of this Endpoint.
+func (e *Endpoint) Dependents() []*Endpoint {
+	return e.dependents
+}
+
+// dependenciesReady returns true once every Endpoint this Endpoint depends on has published data. The caller must
+// hold the application lock.
+func (a *Application) dependenciesReady(e *Endpoint) bool {
+	for _, k := range e.DependsOn {
+		if _, ok := a.data[k]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// refreshDependents makes the dependents of the Endpoint due, so they are checked against its new data.
+func (a *Application) refreshDependents(e *Endpoint) {
+	a.rwMu.Lock()
+	defer a.rwMu.Unlock()
+	for _, d := range e.dependents {
+		d.nextCheckTime = time.Now()
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTemplateFields(t *testing.T) {
+
+	tests := []struct {
+		text   string
+		fields []string
+	}{
+		{"http://www.example.com/data.json", nil},
+		{"{{range .mainfeed.data.tournaments}}http://www.example.com/{{.id}}|||{{end}}", []string{"mainfeed"}},
+		{"{{range .mainfeed.list}}{{.url}}{{$.other.data.id}}|||{{end}}", []string{"mainfeed", "other"}},
+		{"{{with .mainfeed}}{{.data}}{{end}}", []string{"mainfeed"}},
+		{"Bearer {{TrimPrefix .token.data.value \"x\"}}", []string{"token"}},
+		{"{{if .mainfeed}}{{.other}}{{end}}", []string{"mainfeed", "other"}},
+		{"{{range .broken", nil},
+	}
+
+	for _, test := range tests {
+		fields := templateFields(test.text)
+		if !reflect.DeepEqual(fields, test.fields) {
+			t.Errorf("Expected fields %v for template %v but got %v", test.fields, test.text, fields)
+		}
+	}
+}
+
+func TestResolveDependencies(t *testing.T) {
+
+	mainfeed := &Endpoint{Key: "mainfeed", URL: "http://www.example.com/data.json"}
+	secondary := &Endpoint{Key: "secondaryfeed", Dynamic: true, URL: "{{range .mainfeed.data}}{{.url}}|||{{end}}"}
+	detail := &Endpoint{Key: "detailfeed", URL: "http://www.example.com/detail.json", DependsOn: []string{"secondaryfeed"}}
+
+	err := resolveDependencies([]*Endpoint{mainfeed, secondary, detail})
+	if err != nil {
+		t.Fatalf("Unexpected error resolving dependencies: %v", err)
+	}
+	if !reflect.DeepEqual(secondary.DependsOn, []string{"mainfeed"}) {
+		t.Errorf("Expected secondaryfeed to depend on mainfeed but got %v", secondary.DependsOn)
+	}
+	if len(mainfeed.Dependents()) != 1 || mainfeed.Dependents()[0] != secondary {
+		t.Errorf("Expected secondaryfeed to be a dependent of mainfeed but got %v", mainfeed.Dependents())
+	}
+
+	a := &Endpoint{Key: "a", DependsOn: []string{"b"}}
+	b := &Endpoint{Key: "b", RequestBody: "{{.a.data.id}}"}
+	err = resolveDependencies([]*Endpoint{a, b})
+	if err == nil {
+		t.Error("Expected an error for a dependency cycle.")
+	}
+
+	c := &Endpoint{Key: "c", DependsOn: []string{"missing"}}
+	err = resolveDependencies([]*Endpoint{c})
+	if err == nil {
+		t.Error("Expected an error for an unknown dependency.")
+	}
+}
+
+func TestDependenciesReadyAfterTemplateError(t *testing.T) {
+
+	a, e, _ := newTestEndpoint(t, "")
+	e.Key = "secondaryfeed"
+	e.Dynamic = true
+	e.URL = "{{range .mainfeed.urls}}{{.}}|||{{end}"
+	e.currentURLStatus = make(map[string]*URLStatus)
+	detail := &Endpoint{Key: "detailfeed", DependsOn: []string{"secondaryfeed"}}
+
+	if a.dependenciesReady(detail) {
+		t.Error("Expected detailfeed to wait for secondaryfeed to be checked.")
+	}
+	a.checkEndpoint(e)
+	if !a.dependenciesReady(detail) {
+		t.Error("Expected detailfeed to be checked after the URL template of secondaryfeed failed.")
+	}
+}
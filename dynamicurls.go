@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// DynamicURLConfig generates the URLs of a dynamic Endpoint from an array in the result data of another Endpoint.
+type DynamicURLConfig struct {
+	Source string // Key of the Endpoint that provides the data.
+	Path   string // Path to the array in the source result data, ex: data.tournaments
+	URL    string // Template executed for each element of the array to produce a URL.
+	Label  string // Optional template executed for each element to label the URL in the web interface.
+}
+
+// dynamicURLGenerator is the parsed form of a DynamicURLConfig.
+type dynamicURLGenerator struct {
+	source string
+	path   []string
+	url    *template.Template
+	label  *template.Template
+}
+
+var pathIndexRegexp = regexp.MustCompile(`^\[(\d+)\]$`)
+
+func newDynamicURLGenerator(c *DynamicURLConfig) (*dynamicURLGenerator, error) {
+	if c.Source == "" {
+		return nil, fmt.Errorf("dynamicurls requires a source")
+	}
+	if c.Path == "" {
+		return nil, fmt.Errorf("dynamicurls requires a path")
+	}
+	if c.URL == "" {
+		return nil, fmt.Errorf("dynamicurls requires a url")
+	}
+
+	g := &dynamicURLGenerator{source: c.Source, path: strings.Split(c.Path, ".")}
+
+	var err error
+	g.url, err = template.New("url").Funcs(templateFuncs()).Parse(c.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url template: %v", err)
+	}
+	if c.Label != "" {
+		g.label, err = template.New("label").Funcs(templateFuncs()).Parse(c.Label)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label template: %v", err)
+		}
+	}
+	return g, nil
+}
+
+// generate returns the URL for each element selected from the source data, and the label for each URL.
+func (g *dynamicURLGenerator) generate(data map[string]interface{}) ([]string, map[string]string, error) {
+	source, ok := data[g.source]
+	if !ok || source == nil {
+		return nil, nil, fmt.Errorf("no data available from source %v", g.source)
+	}
+
+	elements, err := selectPath(source, g.path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var urls []string
+	labels := make(map[string]string)
+	for _, element := range elements {
+		url, err := executeElementTemplate(g.url, element)
+		if err != nil {
+			return nil, nil, err
+		}
+		url = strings.TrimSpace(url)
+		if url == "" {
+			continue
+		}
+		if _, ok := labels[url]; ok {
+			continue
+		}
+		urls = append(urls, url)
+		labels[url] = url
+
+		if g.label != nil {
+			label, err := executeElementTemplate(g.label, element)
+			if err != nil {
+				return nil, nil, err
+			}
+			if label = strings.TrimSpace(label); label != "" {
+				labels[url] = label
+			}
+		}
+	}
+	return urls, labels, nil
+}
+
+// selectPath navigates the keys of the path and returns the elements of the selected array. The path uses the same
+// syntax as the JSONData validator: object keys separated by periods, [n] to select an element of an array, and [] to
+// iterate over all the elements of an array.
+func selectPath(value interface{}, path []string) ([]interface{}, error) {
+	values := []interface{}{value}
+	for i, key := range path {
+		var next []interface{}
+		for _, v := range values {
+			switch tv := v.(type) {
+			case map[string]interface{}:
+				child, ok := tv[key]
+				if !ok {
+					return nil, fmt.Errorf("key %v not found at %v", key, strings.Join(path[:i+1], "."))
+				}
+				next = append(next, child)
+			case []interface{}:
+				if key == "[]" {
+					next = append(next, tv...)
+					continue
+				}
+				match := pathIndexRegexp.FindStringSubmatch(key)
+				if match == nil {
+					return nil, fmt.Errorf("expected [] or [n] for array at %v", strings.Join(path[:i+1], "."))
+				}
+				index, _ := strconv.Atoi(match[1])
+				if index >= len(tv) {
+					return nil, fmt.Errorf("index %v out of range at %v", index, strings.Join(path[:i+1], "."))
+				}
+				next = append(next, tv[index])
+			default:
+				return nil, fmt.Errorf("unable to select %v from %T at %v", key, v, strings.Join(path[:i+1], "."))
+			}
+		}
+		values = next
+	}
+
+	if path[len(path)-1] == "[]" {
+		return values, nil
+	}
+
+	var elements []interface{}
+	for _, v := range values {
+		array, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("path %v selects %T, not an array", strings.Join(path, "."), v)
+		}
+		elements = append(elements, array...)
+	}
+	return elements, nil
+}
+
+func executeElementTemplate(t *template.Template, element interface{}) (string, error) {
+	buf := new(bytes.Buffer)
+	err := t.Execute(buf, element)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+const dynamicURLTestData = `{"data": {"tournaments": [
+	{"id": 1, "name": "Open", "rounds": [{"id": 11}, {"id": 12}]},
+	{"id": 2, "name": "Classic", "rounds": [{"id": 21}]}
+]}}`
+
+func TestDynamicURLGenerator(t *testing.T) {
+
+	var mainfeed map[string]interface{}
+	json.Unmarshal([]byte(dynamicURLTestData), &mainfeed)
+	data := map[string]interface{}{"mainfeed": mainfeed}
+
+	g, err := newDynamicURLGenerator(&DynamicURLConfig{
+		Source: "mainfeed",
+		Path:   "data.tournaments",
+		URL:    "http://www.example.com/data/{{.id}}/secondary.json",
+		Label:  "{{.name}} & Co",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error creating generator: %v", err)
+	}
+
+	urls, labels, err := g.generate(data)
+	if err != nil {
+		t.Fatalf("Unexpected error generating URLs: %v", err)
+	}
+	expected := []string{"http://www.example.com/data/1/secondary.json", "http://www.example.com/data/2/secondary.json"}
+	if !reflect.DeepEqual(urls, expected) {
+		t.Errorf("Expected URLs %v but got %v", expected, urls)
+	}
+	if labels[expected[0]] != "Open & Co" {
+		t.Errorf("Expected label 'Open & Co' but got '%v'", labels[expected[0]])
+	}
+
+	_, _, err = g.generate(map[string]interface{}{})
+	if err == nil {
+		t.Error("Expected an error when the source has no data.")
+	}
+}
+
+func TestSelectPath(t *testing.T) {
+
+	var value map[string]interface{}
+	json.Unmarshal([]byte(dynamicURLTestData), &value)
+
+	tests := []struct {
+		path  []string
+		count int
+		valid bool
+	}{
+		{[]string{"data", "tournaments"}, 2, true},
+		{[]string{"data", "tournaments", "[]", "rounds"}, 3, true},
+		{[]string{"data", "tournaments", "[0]", "rounds"}, 2, true},
+		{[]string{"data", "tournaments", "[]"}, 2, true},
+		{[]string{"data", "tournaments", "[5]", "rounds"}, 0, false},
+		{[]string{"data", "missing"}, 0, false},
+		{[]string{"data"}, 0, false},
+	}
+
+	for _, test := range tests {
+		elements, err := selectPath(value, test.path)
+		if test.valid && err != nil {
+			t.Errorf("Unexpected error for path %v: %v", test.path, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("Expected an error for path %v", test.path)
+		}
+		if len(elements) != test.count {
+			t.Errorf("Expected %d elements for path %v but got %d", test.count, test.path, len(elements))
+		}
+	}
+}
+
+func TestDynamicResultData(t *testing.T) {
+
+	urls := []string{"http://a", "http://b", "http://c"}
+	results := []map[string]interface{}{{"data": 1}, nil, {"data": 3}}
+
+	d := dynamicResultData(urls, results)
+	byURL := d["byURL"].(map[string]interface{})
+	list := d["list"].([]interface{})
+	if len(byURL) != 2 || len(list) != 2 {
+		t.Fatalf("Expected results for the 2 fetched URLs but got %v", d)
+	}
+	if entry := list[1].(map[string]interface{}); entry["url"] != "http://c" || entry["data"] != 3 {
+		t.Errorf("Expected the list in URL order with the url added but got %v", entry)
+	}
+	if d["data"] != 3 {
+		t.Errorf("Expected the data of the last URL at the top level but got %v", d["data"])
+	}
+
+	empty := dynamicResultData(nil, nil)
+	if len(empty["byURL"].(map[string]interface{})) != 0 || len(empty["list"].([]interface{})) != 0 {
+		t.Errorf("Expected empty result data without URLs but got %v", empty)
+	}
+}
+
+func TestSampledResultData(t *testing.T) {
+
+	urls := []string{"http://a", "http://b", "http://c"}
+	prev := dynamicResultData(urls[:2], []map[string]interface{}{{"data": "a1"}, {"data": "b1"}})
+
+	all := sampledResultData(urls, []string{"http://b"}, []map[string]interface{}{{"data": "b2"}}, prev)
+	if len(all) != 3 {
+		t.Fatalf("Expected result data for all 3 URLs but got %v", all)
+	}
+	if all[0]["data"] != "a1" || all[1]["data"] != "b2" || all[2] != nil {
+		t.Errorf("Expected previous data for a, new data for b and none for c but got %v", all)
+	}
+	if all := sampledResultData(urls, nil, nil, nil); len(all) != 3 || all[0] != nil {
+		t.Errorf("Expected no result data without a sample or previous data but got %v", all)
+	}
+}
+
+func TestFetchDynamicURLsPools(t *testing.T) {
+
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > peak {
+			peak = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	a, e, _ := newTestEndpoint(t, server.URL)
+	e.Dynamic = true
+	e.DynamicConcurrency = 8
+	e.currentURLStatus = make(map[string]*URLStatus)
+	a.pool = newCheckPool(2)
+	global := globalCheckPool
+	globalCheckPool = newCheckPool(3)
+	defer func() { globalCheckPool = global }()
+
+	var urls []string
+	for i := 0; i < 10; i++ {
+		urls = append(urls, fmt.Sprintf("%v/%d", server.URL, i))
+	}
+
+	// The check holds a slot in each pool, as runCheck does.
+	a.pool.acquire(a.cancel)
+	globalCheckPool.acquire(a.cancel)
+	results := a.fetchDynamicURLs(e, urls, nil)
+
+	for i, r := range results {
+		if r == nil {
+			t.Errorf("Expected result data for %v", urls[i])
+		}
+	}
+	if peak > 2 {
+		t.Errorf("Expected at most 2 requests in flight with 2 application slots but got %d", peak)
+	}
+	if len(a.pool.slots) != 1 || len(globalCheckPool.slots) != 1 {
+		t.Errorf("Expected only the slots held by the check to remain taken but got %d and %d", len(a.pool.slots), len(globalCheckPool.slots))
+	}
+}
+
+func TestCheckEndpointNoURLs(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	a, e, results := newTestEndpoint(t, server.URL)
+	startTestDatabase(t)
+	_, notifications := ResultLogChannel, NotificationChannel
+	e.Key = "secondaryfeed"
+	e.Dynamic = true
+	e.URL = "{{range .mainfeed.urls}}{{.}}|||{{end}}"
+	e.DynamicConcurrency = 2
+	e.NotifyURLChanges = true
+	e.currentURLStatus = make(map[string]*URLStatus)
+	e.urlFirstSeen = make(map[string]time.Time)
+
+	a.data["mainfeed"] = map[string]interface{}{"urls": []interface{}{server.URL + "/1", server.URL + "/2"}}
+	a.checkEndpoint(e)
+	if len(results) != 2 || e.CurrentStatus != StatusOK {
+		t.Fatalf("Expected 2 valid results but got %d with status %d", len(results), e.CurrentStatus)
+	}
+
+	// The parent no longer lists any children.
+	a.data["mainfeed"] = map[string]interface{}{"urls": []interface{}{}}
+	a.checkEndpoint(e)
+	if len(e.CurrentURLs) != 0 || len(e.URLStatuses()) != 0 || e.CurrentStatus != StatusUnknown {
+		t.Errorf("Expected no URLs and an unknown status but got %v with status %d", e.CurrentURLs, e.CurrentStatus)
+	}
+	urls, _ := GetDynamicURLs(a.Key, e.Key)
+	for _, u := range urls {
+		if !u.Retired {
+			t.Errorf("Expected %v to be retired", u.URL)
+		}
+	}
+	var changes *URLChanges
+	for len(notifications) > 0 {
+		if n := <-notifications; n.URLChanges != nil {
+			changes = n.URLChanges
+		}
+	}
+	if changes == nil || len(changes.Removed) != 2 {
+		t.Errorf("Expected a notification for the 2 removed URLs but got %+v", changes)
+	}
+	if list := a.data[e.Key].(map[string]interface{})["list"].([]interface{}); len(list) != 0 {
+		t.Errorf("Expected empty result data but got %v", list)
+	}
+}
+
+func TestCheckEndpointTemplateError(t *testing.T) {
+
+	a, e, results := newTestEndpoint(t, "")
+	e.Key = "secondaryfeed"
+	e.Dynamic = true
+	e.URL = "{{range .mainfeed.urls}}{{.}}|||{{end}"
+	e.currentURLStatus = make(map[string]*URLStatus)
+
+	a.checkEndpoint(e)
+	if len(results) != 1 {
+		t.Fatalf("Expected a stored result for the template error but got %d", len(results))
+	}
+	epr := <-results
+	if epr.URL != e.URL || epr.FetchError == nil || epr.FetchError.Class != FetchErrorTemplate {
+		t.Errorf("Expected a template error for the URL template but got %+v", epr)
+	}
+	if e.CurrentStatus != StatusFail || len(e.CurrentValidation) != 1 {
+		t.Errorf("Expected the failure as the current status and validation but got %d and %v", e.CurrentStatus, e.CurrentValidation)
+	}
+	if len(NotificationChannel) != 1 {
+		t.Errorf("Expected a notification for the template error but got %d", len(NotificationChannel))
+	}
+}
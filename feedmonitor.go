@@ -79,6 +79,8 @@ func main() {
 		log.Fatalf("No applications found. Exiting.")
 	}
 
+	globalCheckPool = newCheckPool(configuration.MaxConcurrentChecks)
+
 	StartWatchingConfigDirectory()
 
 	applicationsRWMu.RLock()
@@ -143,6 +145,7 @@ func initializeLogger() *logrus.Entry {
 		logLevel = logrus.DebugLevel
 	}
 	logrus.SetLevel(logLevel)
+	logrus.SetFormatter(&redactingFormatter{Formatter: &logrus.TextFormatter{}})
 
 	if len(configuration.LogFile) > 0 && !strings.EqualFold("console", configuration.LogFile) {
 		logfile, err := os.OpenFile(configuration.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
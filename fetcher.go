@@ -1,16 +1,56 @@
 package main
 
 import (
-	"bytes"
-	"html/template"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
+// Fetch error classes describe why a request could not be completed.
+const (
+	FetchErrorDNS        = "DNS"
+	FetchErrorTimeout    = "Timeout"
+	FetchErrorTLS        = "TLS"
+	FetchErrorConnection = "Connection"
+	FetchErrorRequest    = "Request"
+	FetchErrorAuth       = "Authentication"
+	FetchErrorTemplate   = "Template"
+	FetchErrorSigning    = "Signing"
+)
+
+// idempotentMethods are the request methods that are retried when the server returns a 5xx status. Other methods may
+// have changed state on the server, so only requests that could not be completed are retried.
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+	http.MethodTrace:   true,
+}
+
+// maxRetries is the largest number of retries an Endpoint can define, so the doubling backoff cannot overflow.
+const maxRetries = 10
+
+// maxRedirects is the number of redirects followed before a request fails, matching the net/http default.
+const maxRedirects = 10
+
+func validateRetries(retries int) error {
+	if retries < 0 || retries > maxRetries {
+		return fmt.Errorf("retries must be between 0 and %d, got %d", maxRetries, retries)
+	}
+	return nil
+}
+
 func fetchEndpoint(app *Application, e *Endpoint, url string, data map[string]interface{}) (map[string]interface{}, error) {
 
 	log := log.WithFields(logrus.Fields{"module": "fetcher", "app": app.Key, "endpoint": e.Key, "url": url})
@@ -19,46 +59,170 @@ func fetchEndpoint(app *Application, e *Endpoint, url string, data map[string]in
 
 	epr := &EndpointResult{AppKey: app.Key, EndpointKey: e.Key, URL: url}
 
-	client := &http.Client{}
-	if e.IgnoreRedirects {
-		client = &http.Client{
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				return http.ErrUseLastResponse
-			},
+	// Record each redirect response. The hops are reset at the start of each attempt.
+	var redirects []RedirectHop
+	var hopStart time.Time
+	client := &http.Client{Transport: e.transport, Timeout: e.Timeout}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		// A redirect that is not followed is the final response rather than a hop.
+		if e.IgnoreRedirects {
+			return http.ErrUseLastResponse
+		}
+
+		redirects = append(redirects, RedirectHop{
+			URL:      redactSecrets(via[len(via)-1].URL.String()),
+			Status:   req.Response.StatusCode,
+			Location: redactSecrets(req.Response.Header.Get("Location")),
+			Duration: time.Now().Sub(hopStart),
+		})
+		hopStart = time.Now()
+
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
 		}
+		return nil
 	}
 
-	req, err := http.NewRequest(e.Method, url, strings.NewReader(executeTemplate(e.RequestBody, data)))
+	epr.CheckTime = time.Now()
+	app.rwMu.RLock()
+	if w := app.activeMaintenance(e, epr.CheckTime); w != nil {
+		epr.Maintenance = w.Name
+	}
+	app.rwMu.RUnlock()
+
+	requestBody, err := executeTemplate(e.RequestBody, data)
 	if err != nil {
-		log.Errorf("Error creating new HTTP Request: %v", err)
+		log.Errorf("Error executing request body template: %v", err)
+		publishFetchError(app, e, epr, "Template", FetchErrorTemplate, fmt.Errorf("request body: %v", err))
 		return nil, err
 	}
-
+	headers := make(map[string]string)
 	for k, v := range e.Headers {
-		req.Header.Add(executeTemplate(k, data), executeTemplate(v, data))
+		hk, err := executeTemplate(k, data)
+		if err == nil {
+			headers[hk], err = executeTemplate(v, data)
+		}
+		if err != nil {
+			log.Errorf("Error executing header template: %v", err)
+			publishFetchError(app, e, epr, "Template", FetchErrorTemplate, fmt.Errorf("header %v: %v", k, err))
+			return nil, err
+		}
 	}
 
-	epr.CheckTime = time.Now()
-	resp, err := client.Do(req)
-	if err != nil {
-		webLog.Warnf("Error Performing Endpoint Query. %v", err)
+	// Conditional requests are based on the last result that has a stored body. The body is loaded before the request,
+	// so a body that cannot be loaded results in an unconditional request rather than validating an empty body.
+	var prev *EndpointResult
+	if e.Conditional {
+		prev, _ = GetLastEndpointResult(app.Key, e.Key, url)
+		if prev != nil && prev.BodyHash == "" {
+			prev = nil
+		}
+		if prev != nil {
+			if err := prev.LoadBody(); err != nil {
+				log.Warnf("Unable to load the previous body, sending an unconditional request: %v", err)
+				prev = nil
+			}
+		}
 	}
-	epr.Duration = time.Now().Sub(epr.CheckTime)
 
-	if err != nil {
-		log.Errorf("Error executing HTTP Request: %v", err)
-		return nil, err
+	// The request is always made at least once, even if the retries were not validated.
+	attempts := 1
+	if e.Retries > 0 {
+		attempts += e.Retries
 	}
+	var resp *http.Response
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			backoff := e.RetryBackoff * time.Duration(1<<uint(attempt-2))
+			log.Infof("Retrying request in %v (attempt %d of %d).", backoff, attempt, attempts)
+			select {
+			case <-time.After(backoff):
+			case <-app.cancel:
+				log.Debug("Application stopped, abandoning retries.")
+				return nil, errors.New("application stopped")
+			}
+		}
 
-	if resp.Body != nil {
-		defer resp.Body.Close()
-		epr.Body, err = ioutil.ReadAll(resp.Body)
+		var req *http.Request
+		req, err = http.NewRequest(e.Method, url, strings.NewReader(requestBody))
 		if err != nil {
-			log.Errorf("Error reading response body: %v", err)
+			log.Errorf("Error creating new HTTP Request: %v", err)
+			publishFetchError(app, e, epr, "Fetch", FetchErrorRequest, err)
 			return nil, err
 		}
+
+		for k, v := range headers {
+			req.Header.Add(k, v)
+		}
+
+		if prev != nil {
+			prevHeaders := http.Header(prev.Headers)
+			if etag := prevHeaders.Get("ETag"); etag != "" {
+				req.Header.Set("If-None-Match", etag)
+			}
+			if lastModified := prevHeaders.Get("Last-Modified"); lastModified != "" {
+				req.Header.Set("If-Modified-Since", lastModified)
+			}
+		}
+
+		if e.Authenticator != nil {
+			err = e.Authenticator.authenticate(req)
+			if err != nil {
+				log.Errorf("Error authenticating HTTP Request: %v", err)
+				epr.Request = newRequestInfo(req, requestBody)
+				publishFetchError(app, e, epr, "Authentication", FetchErrorAuth, err)
+				return nil, err
+			}
+		}
+
+		// The signature covers the final headers, so the request is signed last.
+		if e.Signer != nil {
+			err = e.Signer.sign(req, requestBody)
+			if err != nil {
+				log.Errorf("Error signing HTTP Request: %v", err)
+				epr.Request = newRequestInfo(req, requestBody)
+				publishFetchError(app, e, epr, "Signing", FetchErrorSigning, err)
+				return nil, err
+			}
+		}
+
+		epr.Request = newRequestInfo(req, requestBody)
+		epr.Proxy = proxyURL(e.transport, req)
+		redirects = nil
+		start := time.Now()
+		hopStart = start
+		resp, epr.Body, epr.Timing, err = doRequest(client, req)
+		epr.Duration = time.Now().Sub(start)
+		epr.Redirects = redirects
+
+		ra := RequestAttempt{Duration: epr.Duration}
+		if err != nil {
+			ra.Error = redactSecrets(err.Error())
+		} else {
+			ra.Status = resp.StatusCode
+		}
+		epr.Attempts = append(epr.Attempts, ra)
+
+		if err != nil {
+			log.Warnf("Error performing request on attempt %d: %v", attempt, err)
+			continue
+		}
+		if resp.StatusCode >= http.StatusInternalServerError && idempotentMethods[req.Method] {
+			log.Warnf("Received status %d on attempt %d.", resp.StatusCode, attempt)
+			continue
+		}
+		break
+	}
+
+	if err != nil {
+		log.Errorf("Error executing HTTP Request: %v", err)
+		publishFetchError(app, e, epr, "Fetch", classifyFetchError(err), err)
+		return nil, err
 	}
+
 	epr.Headers = resp.Header
+	epr.TLS = newTLSInfo(resp)
+	epr.FinalURL = redactSecrets(resp.Request.URL.String())
 
 	epr.Status = resp.StatusCode
 	epr.Size = resp.ContentLength
@@ -67,18 +231,31 @@ func fetchEndpoint(app *Application, e *Endpoint, url string, data map[string]in
 		epr.Size = int64(len(epr.Body))
 	}
 
-	log.Infof("Fetched result in %v with status %d and %d bytes.", epr.Duration, epr.Status, epr.Size)
+	if resp.StatusCode == http.StatusNotModified && prev != nil {
+		log.Debug("Endpoint not modified, validating the previous body.")
+		epr.NotModified = true
+		epr.Body = prev.Body
+		epr.BodyHash = prev.BodyHash
+		epr.Size = int64(len(epr.Body))
+
+		// Carry the cache validators forward in case the server omitted them from the 304 response.
+		for _, h := range []string{"ETag", "Last-Modified"} {
+			if resp.Header.Get(h) == "" && http.Header(prev.Headers).Get(h) != "" {
+				resp.Header.Set(h, http.Header(prev.Headers).Get(h))
+			}
+		}
+	}
+
+	log.Infof("Fetched result in %v with status %d and %d bytes on attempt %d.", epr.Duration, epr.Status, epr.Size, len(epr.Attempts))
 
 	resultData := make(map[string]interface{})
 	resultData["headers"] = resp.Header
 	vresults := []*ValidationResult{}
 
-	valid := true
 	for _, v := range e.Validators {
 		cont, res := v.validate(e, epr, resultData)
 		vresults = append(vresults, res)
 		if !res.Valid {
-			valid = false
 			log.Infof("Validation Failed for %s validator. Errors: %v", res.Name, res.Errors)
 		}
 		if !cont {
@@ -88,44 +265,121 @@ func fetchEndpoint(app *Application, e *Endpoint, url string, data map[string]in
 
 	epr.ValidationResults = vresults
 
+	publishResult(app, e, epr)
+
+	return resultData, nil
+}
+
+// publishResult sends the result to be stored and notified on, and updates the current status of the Endpoint.
+func publishResult(app *Application, e *Endpoint, epr *EndpointResult) {
+	app.rwMu.Lock()
+	if e.checkResults != nil {
+		// The on-demand check returns the result once the result writer has stored it.
+		epr.stored = make(chan struct{})
+		*e.checkResults = append(*e.checkResults, epr)
+	}
+	app.rwMu.Unlock()
+
 	ResultLogChannel <- epr
 	NotificationChannel <- &Notification{Application: app, Endpoint: e, EndpointResult: epr}
 
-	e.CurrentValidation = vresults
-	if valid {
+	app.rwMu.Lock()
+	defer app.rwMu.Unlock()
+	if e.Dynamic && epr.URL != e.URL {
+		// The validation of each URL is shown in the URL status, so the current validation of a dynamic Endpoint
+		// only holds failures of its URL template, which are recorded against the template itself.
+		e.setURLStatus(epr)
+		return
+	}
+	e.CurrentValidation = epr.ValidationResults
+	if epr.Valid() {
 		e.CurrentStatus = StatusOK
 	} else {
 		e.CurrentStatus = StatusFail
 	}
+}
 
-	return resultData, nil
+// publishFetchError records a request that could not be completed as a failed result, reported under the provided validation name.
+func publishFetchError(app *Application, e *Endpoint, epr *EndpointResult, name string, class string, err error) {
+	epr.FetchError = &FetchError{Class: class, Message: err.Error()}
+	epr.ValidationResults = []*ValidationResult{
+		{Name: name, Errors: []string{epr.FetchError.Class + " error: " + epr.FetchError.Message}},
+	}
+	publishResult(app, e, epr)
 }
 
-func executeTemplate(value string, data map[string]interface{}) string {
-	funcMap := template.FuncMap{
-		"TrimPrefix": strings.TrimPrefix,
-		"TrimSuffix": strings.TrimSuffix,
-		"Split":      strings.Split,
-		"TrimAt": func(s string, sep string) string {
-			i := strings.Index(s, sep)
-			if i >= 0 {
-				return s[:i]
-			}
-			return s
-		},
+// classifyFetchError returns the FetchError class that best describes the error.
+func classifyFetchError(err error) string {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return FetchErrorDNS
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return FetchErrorTimeout
+	}
+
+	// Certificate errors are wrapped in a CertificateVerificationError, and alerts sent by the server during the
+	// handshake, such as a missing client certificate, are reported by crypto/tls as a remote error.
+	var recordErr tls.RecordHeaderError
+	var verificationErr *tls.CertificateVerificationError
+	var unknownAuthErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certInvalidErr x509.CertificateInvalidError
+	var opErr *net.OpError
+	if errors.As(err, &recordErr) || errors.As(err, &verificationErr) || errors.As(err, &unknownAuthErr) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &certInvalidErr) || (errors.As(err, &opErr) && opErr.Op == "remote error") {
+		return FetchErrorTLS
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.As(err, &opErr) {
+		return FetchErrorConnection
 	}
 
-	t, err := template.New("").Funcs(funcMap).Parse(value)
+	return FetchErrorRequest
+}
+
+// newTLSInfo captures the server certificate chain from a response, or returns nil if TLS was not used.
+func newTLSInfo(resp *http.Response) *TLSInfo {
+	if resp.TLS == nil {
+		return nil
+	}
+
+	info := &TLSInfo{ServerName: resp.TLS.ServerName}
+	if info.ServerName == "" {
+		info.ServerName = resp.Request.URL.Hostname()
+	}
+
+	for _, c := range resp.TLS.PeerCertificates {
+		ci := CertificateInfo{
+			Subject:   c.Subject.String(),
+			Issuer:    c.Issuer.String(),
+			DNSNames:  c.DNSNames,
+			NotBefore: c.NotBefore,
+			NotAfter:  c.NotAfter,
+		}
+		for _, ip := range c.IPAddresses {
+			ci.IPAddresses = append(ci.IPAddresses, ip.String())
+		}
+		info.Certificates = append(info.Certificates, ci)
+	}
+	return info
+}
+
+// doRequest performs a single request attempt, reading and closing the response body.
+func doRequest(client *http.Client, req *http.Request) (*http.Response, []byte, RequestTiming, error) {
+	tracer := &requestTracer{}
+	resp, err := client.Do(tracer.trace(req))
 	if err != nil {
-		log.Warnf("Unable to parse template %v. Error: %v", value, err)
-		return value
+		return nil, nil, tracer.timing(), err
 	}
+	defer resp.Body.Close()
 
-	buf := new(bytes.Buffer)
-	err = t.Execute(buf, data)
+	body, err := ioutil.ReadAll(resp.Body)
+	tracer.bodyRead()
 	if err != nil {
-		log.Warnf("Unable to execute template %v. Error: %v", value, err)
-		return value
+		return nil, nil, tracer.timing(), err
 	}
-	return buf.String()
+	return resp, body, tracer.timing(), nil
 }
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newTestEndpoint returns an application with a static Endpoint for the URL, and sets up the logger and the result
+// and notification channels used by the fetcher.
+func newTestEndpoint(t *testing.T, url string) (*Application, *Endpoint, chan *EndpointResult) {
+	if log == nil {
+		logger := logrus.New()
+		logger.Out = ioutil.Discard
+		log = logrus.NewEntry(logger)
+	}
+	results, _ := captureResults(t)
+	if globalCheckPool == nil {
+		globalCheckPool = newCheckPool(defaultMaxConcurrentChecks)
+	}
+
+	transport, err := newTransport(nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error creating transport: %v", err)
+	}
+	e := &Endpoint{Key: "mainfeed", Name: "Main Feed", URL: url, Method: http.MethodGet, Timeout: 5 * time.Second, transport: transport}
+	a := &Application{Key: "app", Name: "App", cancel: make(chan bool), rwMu: &sync.RWMutex{}, pool: newCheckPool(defaultAppConcurrentChecks), data: make(map[string]interface{}), Endpoints: []*Endpoint{e}}
+	return a, e, results
+}
+
+// startTestDatabase opens a database in a temporary directory for the duration of the test.
+func startTestDatabase(t *testing.T) {
+	if err := StartDatabase(filepath.Join(t.TempDir(), "feedmonitor.db"), log); err != nil {
+		t.Fatalf("Unexpected error opening the database: %v", err)
+	}
+	t.Cleanup(StopDatabase)
+}
+
+// flakyServer returns a server that responds with status to the first failures requests, and 200 after that.
+func flakyServer(failures int32, status int) (*httptest.Server, *int32) {
+	var requests int32
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(`{"data": "ok"}`))
+	})), &requests
+}
+
+func TestFetchEndpointRetries(t *testing.T) {
+
+	server, requests := flakyServer(2, http.StatusServiceUnavailable)
+	defer server.Close()
+
+	a, e, results := newTestEndpoint(t, server.URL)
+	e.Retries = 2
+	e.RetryBackoff = 10 * time.Millisecond
+
+	start := time.Now()
+	if _, err := fetchEndpoint(a, e, e.URL, nil); err != nil {
+		t.Fatalf("Unexpected error fetching endpoint: %v", err)
+	}
+	epr := <-results
+	if len(epr.Attempts) != 3 || epr.Status != http.StatusOK || !epr.Recovered() {
+		t.Fatalf("Expected a recovered result on attempt 3 but got status %d on attempt %d", epr.Status, len(epr.Attempts))
+	}
+	for i, status := range []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK} {
+		if epr.Attempts[i].Status != status || epr.Attempts[i].Error != "" || epr.Attempts[i].Duration <= 0 {
+			t.Errorf("Expected attempt %d to have status %d but got %+v", i+1, status, epr.Attempts[i])
+		}
+	}
+	// The backoff doubles, so the retries wait 10ms and then 20ms.
+	if elapsed := time.Now().Sub(start); elapsed < 30*time.Millisecond {
+		t.Errorf("Expected at least 30ms of backoff but the fetch took %v", elapsed)
+	}
+	if *requests != 3 {
+		t.Errorf("Expected 3 requests but the server received %d", *requests)
+	}
+}
+
+func TestFetchEndpointRetriesExhausted(t *testing.T) {
+
+	server, requests := flakyServer(5, http.StatusBadGateway)
+	defer server.Close()
+
+	a, e, results := newTestEndpoint(t, server.URL)
+	e.Retries = 1
+	e.RetryBackoff = time.Millisecond
+
+	fetchEndpoint(a, e, e.URL, nil)
+	epr := <-results
+	if len(epr.Attempts) != 2 || epr.Status != http.StatusBadGateway || *requests != 2 {
+		t.Errorf("Expected status 502 after 2 attempts but got %d after %d, with %d requests", epr.Status, len(epr.Attempts), *requests)
+	}
+}
+
+func TestFetchEndpointRetryNotIdempotent(t *testing.T) {
+
+	server, requests := flakyServer(1, http.StatusInternalServerError)
+	defer server.Close()
+
+	a, e, results := newTestEndpoint(t, server.URL)
+	e.Method = http.MethodPost
+	e.Retries = 2
+	e.RetryBackoff = time.Millisecond
+
+	fetchEndpoint(a, e, e.URL, nil)
+	epr := <-results
+	if len(epr.Attempts) != 1 || epr.Status != http.StatusInternalServerError || *requests != 1 {
+		t.Errorf("Expected a POST to not be retried on a 5xx but got %d attempts and %d requests", len(epr.Attempts), *requests)
+	}
+}
+
+func TestFetchEndpointNegativeRetries(t *testing.T) {
+
+	server, requests := flakyServer(1, http.StatusServiceUnavailable)
+	defer server.Close()
+
+	a, e, results := newTestEndpoint(t, server.URL)
+	e.Retries = -1
+
+	if _, err := fetchEndpoint(a, e, e.URL, nil); err != nil {
+		t.Fatalf("Unexpected error fetching endpoint: %v", err)
+	}
+	epr := <-results
+	if len(epr.Attempts) != 1 || epr.Status != http.StatusServiceUnavailable || *requests != 1 {
+		t.Errorf("Expected a single attempt with negative retries but got %d attempts and %d requests", len(epr.Attempts), *requests)
+	}
+
+	for _, retries := range []int{-1, maxRetries + 1} {
+		if err := validateRetries(retries); err == nil {
+			t.Errorf("Expected an error for %d retries.", retries)
+		}
+	}
+	if err := validateRetries(maxRetries); err != nil {
+		t.Errorf("Unexpected error for %d retries: %v", maxRetries, err)
+	}
+}
+
+func TestFetchEndpointRetryCancel(t *testing.T) {
+
+	server, _ := flakyServer(5, http.StatusServiceUnavailable)
+	defer server.Close()
+
+	a, e, results := newTestEndpoint(t, server.URL)
+	e.Retries = 1
+	e.RetryBackoff = time.Hour
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(a.cancel)
+	}()
+	done := make(chan error)
+	go func() {
+		_, err := fetchEndpoint(a, e, e.URL, nil)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Expected an error when the application stops during the backoff.")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the backoff to stop when the application stops.")
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no result for an abandoned check but got %d", len(results))
+	}
+}
+
+func TestFetchEndpointConditionalMissingBody(t *testing.T) {
+
+	var conditional int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" {
+			atomic.AddInt32(&conditional, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v2"`)
+		w.Write([]byte(`{"data": "ok"}`))
+	}))
+	defer server.Close()
+
+	a, e, results := newTestEndpoint(t, server.URL)
+	startTestDatabase(t)
+	gitRoot := configuration.GitRoot
+	configuration.GitRoot = t.TempDir()
+	defer func() { configuration.GitRoot = gitRoot }()
+	e.Conditional = true
+
+	// The previous result refers to a body that is not in the repository.
+	WriteEndpointResult(&EndpointResult{AppKey: a.Key, EndpointKey: e.Key, URL: e.URL, CheckTime: time.Now().Add(-time.Minute),
+		BodyHash: "0123456789abcdef0123456789abcdef01234567", Headers: map[string][]string{"Etag": {`"v1"`}}})
+
+	fetchEndpoint(a, e, e.URL, nil)
+	epr := <-results
+	if conditional != 0 || epr.NotModified || epr.Status != http.StatusOK || string(epr.Body) != `{"data": "ok"}` {
+		t.Errorf("Expected an unconditional request when the previous body cannot be loaded but got status %d, not modified %v", epr.Status, epr.NotModified)
+	}
+}
+
+func TestFetchEndpointRedirects(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.Redirect(w, r, "/feed.json", http.StatusFound)
+			return
+		}
+		w.Write([]byte(`{"data": "ok"}`))
+	}))
+	defer server.Close()
+
+	a, e, results := newTestEndpoint(t, server.URL+"/")
+	v := &ValidateRedirect{}
+	v.initialize("Redirect", map[string]interface{}{"maxhops": 0})
+
+	fetchEndpoint(a, e, e.URL, nil)
+	epr := <-results
+	if len(epr.Redirects) != 1 || epr.Status != http.StatusOK {
+		t.Errorf("Expected the redirect to be followed and recorded but got status %d with %d redirects", epr.Status, len(epr.Redirects))
+	}
+	if _, res := v.validate(e, epr, nil); res.Valid {
+		t.Error("Followed redirect sent to ValidateRedirect with maxhops 0 but didn't recieve an error.")
+	}
+
+	// A redirect that is not followed is the final response and is not counted as a hop.
+	e.IgnoreRedirects = true
+	fetchEndpoint(a, e, e.URL, nil)
+	epr = <-results
+	if len(epr.Redirects) != 0 || epr.Status != http.StatusFound {
+		t.Errorf("Expected the redirect response without any hops but got status %d with %d redirects", epr.Status, len(epr.Redirects))
+	}
+	if _, res := v.validate(e, epr, nil); !res.Valid {
+		t.Errorf("Ignored redirect sent to ValidateRedirect with maxhops 0 but recieved errors: %v", res.Errors)
+	}
+}
+
+func TestClassifyFetchError(t *testing.T) {
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	}))
+	defer slow.Close()
+	// A server that does not speak TLS or HTTP.
+	plain, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+	defer plain.Close()
+	go func() {
+		for {
+			c, err := plain.Accept()
+			if err != nil {
+				return
+			}
+			c.Write([]byte("garbage response\n"))
+			c.Close()
+		}
+	}()
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer secure.Close()
+	clientCert := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	clientCert.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	clientCert.StartTLS()
+	defer clientCert.Close()
+
+	// A port that was just released refuses connections.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+	refused := "http://" + l.Addr().String()
+	l.Close()
+
+	_, port, _ := net.SplitHostPort(secure.Listener.Addr().String())
+	trusted := secure.Client()
+
+	tests := []struct {
+		name   string
+		client *http.Client
+		url    string
+		class  string
+	}{
+		{"DNS", http.DefaultClient, "http://feedmonitor.invalid/", FetchErrorDNS},
+		{"Refused", http.DefaultClient, refused, FetchErrorConnection},
+		{"Timeout", &http.Client{Timeout: 50 * time.Millisecond}, slow.URL, FetchErrorTimeout},
+		{"Unknown Authority", http.DefaultClient, secure.URL, FetchErrorTLS},
+		{"Hostname", trusted, "https://localhost:" + port, FetchErrorTLS},
+		{"Not TLS", trusted, "https://" + plain.Addr().String(), FetchErrorTLS},
+		{"Client Certificate", clientCert.Client(), clientCert.URL, FetchErrorTLS},
+		{"Request", http.DefaultClient, "ftp://www.example.com/data.json", FetchErrorRequest},
+	}
+
+	for _, test := range tests {
+		resp, err := test.client.Get(test.url)
+		if err == nil {
+			resp.Body.Close()
+			t.Errorf("Expected an error for %v", test.name)
+			continue
+		}
+		if class := classifyFetchError(err); class != test.class {
+			t.Errorf("Expected class %v for %v but got %v: %v", test.class, test.name, class, err)
+		}
+	}
+}
@@ -56,6 +56,11 @@ func reloadConfigFile(event fsnotify.Event) {
 			}
 
 			updatedApp := configuration.initializeApplication(app.FileName)
+			if updatedApp == nil {
+				log.Errorf("Unable to reload configuration from file %v. Continuing with the previous configuration. See previous error.", app.FileName)
+				applicationsRWMu.Unlock()
+				return
+			}
 			app.stopFeedMonitor()
 			applications[i] = updatedApp
 			updatedApp.startFeedMonitor(mainWg)
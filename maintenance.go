@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Maintenance modes define what happens to the Endpoints during a maintenance window.
+const (
+	MaintenanceModeSkip    = "skip"    // The Endpoints are not checked.
+	MaintenanceModeSilence = "silence" // The Endpoints are checked, but no notifications are sent.
+)
+
+// maintenanceTimeLayouts are the accepted formats for the start and end of a one-off maintenance window.
+var maintenanceTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04"}
+
+// MaintenanceConfig defines a one-off window with a start and end, or a recurring window with a cron expression and a
+// duration.
+type MaintenanceConfig struct {
+	ID       string // Set for windows created in the web interface.
+	Endpoint string // Key of the Endpoint the window applies to, or empty for every Endpoint in the application.
+	Name     string
+	Start    string        // Start of a one-off window, ex: 2018-03-01 02:00
+	End      string        // End of a one-off window.
+	Cron     string        // Start times of a recurring window, ex: 0 2 * * sun
+	Duration time.Duration // Length of a recurring window, ex: 2h
+	TimeZone string        // Time zone of the start, end and cron times, ex: America/Chicago
+	Mode     string        // skip or silence
+}
+
+// MaintenanceWindow is the parsed form of a MaintenanceConfig.
+type MaintenanceWindow struct {
+	MaintenanceConfig
+	location *time.Location
+	start    time.Time
+	end      time.Time
+	cron     *cronSchedule
+}
+
+func newMaintenanceWindow(c MaintenanceConfig) (*MaintenanceWindow, error) {
+	w := &MaintenanceWindow{MaintenanceConfig: c, location: time.Local}
+	if w.Name == "" {
+		w.Name = "Maintenance"
+	}
+
+	switch strings.ToLower(c.Mode) {
+	case "", MaintenanceModeSilence:
+		w.Mode = MaintenanceModeSilence
+	case MaintenanceModeSkip:
+		w.Mode = MaintenanceModeSkip
+	default:
+		return nil, fmt.Errorf("unknown maintenance mode %v, expected skip or silence", c.Mode)
+	}
+
+	if c.TimeZone != "" {
+		loc, err := time.LoadLocation(c.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("unknown time zone %v: %v", c.TimeZone, err)
+		}
+		w.location = loc
+	}
+
+	if c.Cron != "" {
+		if c.Start != "" || c.End != "" {
+			return nil, fmt.Errorf("a maintenance window has either a cron expression or a start and end, not both")
+		}
+		if c.Duration <= 0 {
+			return nil, fmt.Errorf("a recurring maintenance window requires a duration")
+		}
+		var err error
+		w.cron, err = parseCron(c.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %v: %v", c.Cron, err)
+		}
+		if w.cron.next(time.Now().In(w.location)).IsZero() {
+			return nil, fmt.Errorf("cron expression %v never matches", c.Cron)
+		}
+		return w, nil
+	}
+
+	if c.Start == "" || c.End == "" {
+		return nil, fmt.Errorf("a maintenance window requires a start and end, or a cron expression and duration")
+	}
+	var err error
+	if w.start, err = parseMaintenanceTime(c.Start, w.location); err != nil {
+		return nil, err
+	}
+	if w.end, err = parseMaintenanceTime(c.End, w.location); err != nil {
+		return nil, err
+	}
+	if !w.end.After(w.start) {
+		return nil, fmt.Errorf("the maintenance window ends before it starts")
+	}
+	return w, nil
+}
+
+func parseMaintenanceTime(value string, loc *time.Location) (time.Time, error) {
+	for _, layout := range maintenanceTimeLayouts {
+		if t, err := time.ParseInLocation(layout, strings.TrimSpace(value), loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %v, expected the format 2006-01-02 15:04", value)
+}
+
+// occurrence returns the start and end of the occurrence of the window in effect at t, or the next occurrence if the
+// window is not in effect. Zero times are returned if the window has ended.
+func (w *MaintenanceWindow) occurrence(t time.Time) (time.Time, time.Time) {
+	if w.cron == nil {
+		if t.Before(w.end) {
+			return w.start, w.end
+		}
+		return time.Time{}, time.Time{}
+	}
+
+	// Start from the earliest occurrence that could still be in effect.
+	for start := w.cron.next(t.Add(-w.Duration - time.Minute).In(w.location)); !start.IsZero(); start = w.cron.next(start) {
+		if end := start.Add(w.Duration); t.Before(end) {
+			return start, end
+		}
+	}
+	return time.Time{}, time.Time{}
+}
+
+// active returns true if t is within the window.
+func (w *MaintenanceWindow) active(t time.Time) bool {
+	start, end := w.occurrence(t)
+	return !start.IsZero() && !t.Before(start) && t.Before(end)
+}
+
+// Active returns true if the window is currently in effect.
+func (w *MaintenanceWindow) Active() bool {
+	return w.active(time.Now())
+}
+
+// Schedule describes when the window occurs.
+func (w *MaintenanceWindow) Schedule() string {
+	if w.cron != nil {
+		return fmt.Sprintf("%v for %v (%v)", w.Cron, w.Duration, w.location)
+	}
+	return fmt.Sprintf("%v to %v", w.start.Format("2006-01-02 15:04 MST"), w.end.Format("2006-01-02 15:04 MST"))
+}
+
+// State describes whether the window is in effect, upcoming or has ended.
+func (w *MaintenanceWindow) State() string {
+	now := time.Now()
+	start, end := w.occurrence(now)
+	switch {
+	case start.IsZero():
+		return "Ended"
+	case now.Before(start):
+		return fmt.Sprintf("Next %v to %v", start.Format("2006-01-02 15:04 MST"), end.Format("2006-01-02 15:04 MST"))
+	}
+	return fmt.Sprintf("Active until %v", end.Format("2006-01-02 15:04 MST"))
+}
+
+// activeMaintenance returns the maintenance window in effect for the Endpoint at t, preferring a window that skips
+// checks, or nil if there is none. The caller must hold the application lock.
+func (a *Application) activeMaintenance(e *Endpoint, t time.Time) *MaintenanceWindow {
+	var active *MaintenanceWindow
+	for _, windows := range [][]*MaintenanceWindow{a.Maintenance, e.Maintenance} {
+		for _, w := range windows {
+			if w.active(t) && (active == nil || w.Mode == MaintenanceModeSkip) {
+				active = w
+			}
+		}
+	}
+	return active
+}
+
+// MaintenanceWindows returns the windows of the application, followed by the windows of the Endpoint if it is not nil.
+// The caller must hold the application lock.
+func (a *Application) MaintenanceWindows(e *Endpoint) []*MaintenanceWindow {
+	windows := append([]*MaintenanceWindow{}, a.Maintenance...)
+	if e != nil {
+		windows = append(windows, e.Maintenance...)
+	}
+	return windows
+}
+
+// addMaintenance adds the window to the application, or to its Endpoint. The caller must hold the application lock.
+func (a *Application) addMaintenance(w *MaintenanceWindow) error {
+	if w.Endpoint == "" {
+		a.Maintenance = append(a.Maintenance, w)
+		return nil
+	}
+	for _, e := range a.Endpoints {
+		if strings.EqualFold(e.Key, w.Endpoint) {
+			e.Maintenance = append(e.Maintenance, w)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown endpoint %v", w.Endpoint)
+}
+
+// removeMaintenance removes the window created in the web interface with the provided ID. The caller must hold the
+// application lock.
+func (a *Application) removeMaintenance(id string) {
+	remove := func(windows []*MaintenanceWindow) []*MaintenanceWindow {
+		var kept []*MaintenanceWindow
+		for _, w := range windows {
+			// Windows from the configuration files have no ID, so they are never removed.
+			if w.ID == "" || w.ID != id {
+				kept = append(kept, w)
+			}
+		}
+		return kept
+	}
+	a.Maintenance = remove(a.Maintenance)
+	for _, e := range a.Endpoints {
+		e.Maintenance = remove(e.Maintenance)
+	}
+}
+
+// loadMaintenance adds the windows created in the web interface to the application.
+func (a *Application) loadMaintenance() {
+	configs, err := GetMaintenanceWindows(a.Key)
+	if err != nil {
+		log.Errorf("Unable to load the maintenance windows for app %v. %v", a.Name, err)
+		return
+	}
+	for _, c := range configs {
+		w, err := newMaintenanceWindow(c)
+		if err == nil {
+			err = a.addMaintenance(w)
+		}
+		if err != nil {
+			log.Warnf("Ignoring maintenance window %v (%v) in app %v. %v", c.Name, c.ID, a.Name, err)
+		}
+	}
+}
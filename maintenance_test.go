@@ -0,0 +1,94 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindowActive(t *testing.T) {
+
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Skipf("Time zone data unavailable: %v", err)
+	}
+
+	oneOff, err := newMaintenanceWindow(MaintenanceConfig{Start: "2018-03-01 02:00", End: "2018-03-01T04:00", TimeZone: "America/Chicago"})
+	if err != nil {
+		t.Fatalf("Unexpected error creating one-off window: %v", err)
+	}
+	recurring, err := newMaintenanceWindow(MaintenanceConfig{Cron: "30 23 * * sat", Duration: 2 * time.Hour, TimeZone: "America/Chicago", Mode: "skip"})
+	if err != nil {
+		t.Fatalf("Unexpected error creating recurring window: %v", err)
+	}
+	if oneOff.Mode != MaintenanceModeSilence || oneOff.Name != "Maintenance" {
+		t.Errorf("Expected the default mode and name but got %v and %v", oneOff.Mode, oneOff.Name)
+	}
+
+	tests := []struct {
+		window *MaintenanceWindow
+		t      time.Time
+		active bool
+	}{
+		{oneOff, time.Date(2018, 3, 1, 1, 59, 0, 0, chicago), false},
+		{oneOff, time.Date(2018, 3, 1, 2, 0, 0, 0, chicago), true},
+		{oneOff, time.Date(2018, 3, 1, 3, 59, 0, 0, chicago), true},
+		{oneOff, time.Date(2018, 3, 1, 4, 0, 0, 0, chicago), false},
+		// March 3rd 2018 was a Saturday, and the window runs past midnight.
+		{recurring, time.Date(2018, 3, 3, 23, 29, 0, 0, chicago), false},
+		{recurring, time.Date(2018, 3, 3, 23, 30, 0, 0, chicago), true},
+		{recurring, time.Date(2018, 3, 4, 1, 29, 0, 0, chicago), true},
+		{recurring, time.Date(2018, 3, 4, 1, 30, 0, 0, chicago), false},
+		{recurring, time.Date(2018, 3, 4, 23, 30, 0, 0, chicago), false},
+	}
+	for _, test := range tests {
+		if active := test.window.active(test.t); active != test.active {
+			t.Errorf("Expected active %v for %v at %v", test.active, test.window.Schedule(), test.t)
+		}
+	}
+
+	e := &Endpoint{Key: "mainfeed", Maintenance: []*MaintenanceWindow{recurring}}
+	a := &Application{Maintenance: []*MaintenanceWindow{oneOff}}
+	if w := a.activeMaintenance(e, time.Date(2018, 3, 1, 3, 0, 0, 0, chicago)); w != oneOff {
+		t.Errorf("Expected the application window to apply to the endpoint but got %v", w)
+	}
+	if w := a.activeMaintenance(e, time.Date(2018, 3, 2, 3, 0, 0, 0, chicago)); w != nil {
+		t.Errorf("Expected no active window but got %v", w.Name)
+	}
+}
+
+func TestMaintenanceWindowErrors(t *testing.T) {
+
+	for _, c := range []MaintenanceConfig{
+		{},
+		{Start: "2018-03-01 02:00"},
+		{Start: "2018-03-01 04:00", End: "2018-03-01 02:00"},
+		{Start: "March 1st", End: "2018-03-01 02:00"},
+		{Cron: "0 2 * * sun"},
+		{Cron: "0 2 * * sun", Duration: time.Hour, Start: "2018-03-01 02:00"},
+		{Cron: "0 2 * * sun", Duration: time.Hour, Mode: "ignore"},
+		{Cron: "0 2 * * sun", Duration: time.Hour, TimeZone: "Mars/Olympus_Mons"},
+	} {
+		if _, err := newMaintenanceWindow(c); err == nil {
+			t.Errorf("Expected an error for maintenance window %+v", c)
+		}
+	}
+}
+
+func TestRemoveMaintenance(t *testing.T) {
+
+	configured := &MaintenanceWindow{Name: "Configured"}
+	created := &MaintenanceWindow{ID: "1", Name: "Created"}
+	e := &Endpoint{Key: "mainfeed", Maintenance: []*MaintenanceWindow{{Name: "Endpoint"}, {ID: "2", Name: "Endpoint Created"}}}
+	a := &Application{Maintenance: []*MaintenanceWindow{configured, created}, Endpoints: []*Endpoint{e}}
+
+	a.removeMaintenance("")
+	if len(a.Maintenance) != 2 || len(e.Maintenance) != 2 {
+		t.Fatalf("Expected an empty id to remove no windows, got %d and %d", len(a.Maintenance), len(e.Maintenance))
+	}
+
+	a.removeMaintenance("1")
+	a.removeMaintenance("2")
+	if len(a.Maintenance) != 1 || a.Maintenance[0] != configured || len(e.Maintenance) != 1 || e.Maintenance[0].Name != "Endpoint" {
+		t.Errorf("Expected only the configured windows to remain, got %v and %v", a.Maintenance, e.Maintenance)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"sync"
 )
 
@@ -10,6 +11,32 @@ type Notification struct {
 	Application    *Application
 	Endpoint       *Endpoint
 	EndpointResult *EndpointResult
+	URLChanges     *URLChanges // Set instead of the EndpointResult when the URLs of a dynamic Endpoint change.
+}
+
+// URLChanges lists the URLs that appeared and disappeared from a dynamic Endpoint.
+type URLChanges struct {
+	Added   []DynamicURL
+	Removed []DynamicURL
+}
+
+// describe returns a summary of the changes, with each URL on its own line separated by the provided line break.
+func (c *URLChanges) describe(lineBreak string) string {
+	var message string
+	for _, du := range c.Added {
+		message = fmt.Sprintf("%vAdded: %v%v", message, du.describe(), lineBreak)
+	}
+	for _, du := range c.Removed {
+		message = fmt.Sprintf("%vRemoved: %v%v", message, du.describe(), lineBreak)
+	}
+	return message
+}
+
+func (du DynamicURL) describe() string {
+	if du.Label == "" || du.Label == du.URL {
+		return du.URL
+	}
+	return fmt.Sprintf("%v (%v)", du.Label, du.URL)
 }
 
 // StartNotificationHandler starts the goroutine to process notifications.
@@ -43,7 +70,19 @@ func StartNotificationHandler(ctx context.Context, wg *sync.WaitGroup) chan *Not
 
 func shouldNotify(n *Notification) bool {
 
+	if n.URLChanges != nil {
+		return true
+	}
+
+	if n.EndpointResult.Maintenance != "" {
+		return false
+	}
+
+	// Results captured during maintenance were not notified on, so compare against the last result before it.
 	prevEpr, _ := GetEndpointResultPrev(n.EndpointResult.AppKey, n.EndpointResult.EndpointKey, n.EndpointResult.URL, n.EndpointResult.CheckTime)
+	for prevEpr != nil && prevEpr.Maintenance != "" {
+		prevEpr, _ = GetEndpointResultPrev(prevEpr.AppKey, prevEpr.EndpointKey, prevEpr.URL, prevEpr.CheckTime)
+	}
 
 	if !n.EndpointResult.Valid() && (prevEpr == nil || prevEpr.Valid()) {
 		return true
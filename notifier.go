@@ -22,7 +22,9 @@ func (s *StandardErrorNotifier) initialize(name string, data map[string]interfac
 func (s *StandardErrorNotifier) notify(n *Notification) {
 
 	var message string
-	if n.EndpointResult.Valid() {
+	if n.URLChanges != nil {
+		message = fmt.Sprintf("URLs changed on %v feed name '%v'.\r\n%v", n.Application.Name, n.Endpoint.Name, n.URLChanges.describe("\r\n"))
+	} else if n.EndpointResult.Valid() {
 		message = fmt.Sprintf("Successfully checked %v feed %v at URL: %v in %v", n.Application.Name, n.Endpoint.Name, n.EndpointResult.URL, n.EndpointResult.Duration)
 	} else {
 		errors := ""
@@ -56,7 +58,11 @@ func (h *HipChatNotifer) notify(n *Notification) {
 
 	var message string
 	var color hipchat.Color
-	if n.EndpointResult.Valid() {
+	if n.URLChanges != nil {
+		resultURL := fmt.Sprintf("%v/app/%v/%v/urls", configuration.WebRoot, n.Application.Key, n.Endpoint.Key)
+		message = fmt.Sprintf("URLs changed on %v feed name '%v'.<br/>%v<a href=\"%v\">View URLs</a>", n.Application.Name, n.Endpoint.Name, n.URLChanges.describe("<br/>"), resultURL)
+		color = hipchat.ColorYellow
+	} else if n.EndpointResult.Valid() {
 		message = fmt.Sprintf("Successfully checked %v feed %v at URL: %v in %v", n.Application.Name, n.Endpoint.Name, n.EndpointResult.URL, n.EndpointResult.Duration)
 		color = hipchat.ColorGreen
 	} else {
@@ -168,6 +174,28 @@ func (t *TeamsNotifer) notify(n *Notification) {
 		"text": "%v"
 	},`
 
+	urlChangesFactset := `{
+		"facts": [
+			{
+				"name": "Application:",
+				"value": "%v"
+			},
+			{
+				"name": "Endpoint Name:",
+				"value": "%v"
+			},
+			{
+				"name": "Added:",
+				"value": "%v"
+			},
+			{
+				"name": "Removed:",
+				"value": "%v"
+			}
+		],
+		"text": "%v"
+	}`
+
 	type TemplateData struct {
 		Title   string
 		Color   string
@@ -177,8 +205,14 @@ func (t *TeamsNotifer) notify(n *Notification) {
 	}
 
 	data := TemplateData{}
-	data.URL = fmt.Sprintf("%v/app/%v/%v/", configuration.WebRoot, n.Application.Key, n.EndpointResult.EndpointKey)
-	if n.EndpointResult.Valid() {
+	data.URL = fmt.Sprintf("%v/app/%v/%v/", configuration.WebRoot, n.Application.Key, n.Endpoint.Key)
+	if n.URLChanges != nil {
+		data.Title = "FeedMonitor URLs Changed"
+		data.Message = n.URLChanges.describe("<br/>")
+		data.Details = fmt.Sprintf(urlChangesFactset, n.Application.Name, n.Endpoint.Name, len(n.URLChanges.Added), len(n.URLChanges.Removed), data.Message)
+		data.Color = "FFA500"
+		data.URL = fmt.Sprintf("%v/app/%v/%v/urls", configuration.WebRoot, n.Application.Key, n.Endpoint.Key)
+	} else if n.EndpointResult.Valid() {
 		data.Title = "FeedMonitor Fetch Successful"
 		data.Message = "Feed fetched and validated successfully."
 		data.Details = fmt.Sprintf(successFactset, n.Application.Name, n.Endpoint.Name, n.EndpointResult.URL, n.EndpointResult.Duration, data.Message)
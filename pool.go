@@ -0,0 +1,65 @@
+package main
+
+// defaultMaxConcurrentChecks is the global limit on concurrent Endpoint checks when the configuration does not define one.
+const defaultMaxConcurrentChecks = 10
+
+// defaultAppConcurrentChecks is the per application limit on concurrent Endpoint checks when the configuration does not define one.
+const defaultAppConcurrentChecks = 4
+
+// defaultDynamicConcurrency is the number of dynamic URLs fetched at the same time when the Endpoint does not define it.
+const defaultDynamicConcurrency = 4
+
+// globalCheckPool limits the number of Endpoint checks running across all applications.
+var globalCheckPool *checkPool
+
+// checkPool limits the number of Endpoint checks that run concurrently.
+type checkPool struct {
+	slots chan struct{}
+}
+
+func newCheckPool(size int) *checkPool {
+	return &checkPool{slots: make(chan struct{}, size)}
+}
+
+// acquire blocks until a slot is available, returning false if cancel is closed first.
+func (p *checkPool) acquire(cancel <-chan bool) bool {
+	select {
+	case p.slots <- struct{}{}:
+		return true
+	case <-cancel:
+		return false
+	}
+}
+
+// release returns a slot acquired with acquire.
+func (p *checkPool) release() {
+	<-p.slots
+}
+
+// tryAcquire takes a slot if one is free, without blocking.
+func (p *checkPool) tryAcquire() bool {
+	select {
+	case p.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// tryAcquireSlots takes a slot in the application and global pools if both are free, without blocking.
+func (a *Application) tryAcquireSlots() bool {
+	if !a.pool.tryAcquire() {
+		return false
+	}
+	if !globalCheckPool.tryAcquire() {
+		a.pool.release()
+		return false
+	}
+	return true
+}
+
+// releaseSlots returns the slots taken with tryAcquireSlots.
+func (a *Application) releaseSlots() {
+	globalCheckPool.release()
+	a.pool.release()
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// concurrencyServer is a slow server that records the peak number of requests in progress overall, per application
+// and per Endpoint, using paths of the form /app/endpoint.
+type concurrencyServer struct {
+	mu        sync.Mutex
+	inFlight  map[string]int
+	peak      map[string]int
+	completed map[string]int
+}
+
+func (s *concurrencyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	keys := []string{"", strings.Split(path, "/")[0], path}
+
+	s.mu.Lock()
+	for _, k := range keys {
+		s.inFlight[k]++
+		if s.inFlight[k] > s.peak[k] {
+			s.peak[k] = s.inFlight[k]
+		}
+	}
+	s.mu.Unlock()
+
+	time.Sleep(300 * time.Millisecond)
+	w.Write([]byte(`{"data": "ok"}`))
+
+	s.mu.Lock()
+	for _, k := range keys {
+		s.inFlight[k]--
+	}
+	s.completed[path]++
+	s.mu.Unlock()
+}
+
+func TestFeedMonitorConcurrency(t *testing.T) {
+
+	cs := &concurrencyServer{inFlight: make(map[string]int), peak: make(map[string]int), completed: make(map[string]int)}
+	server := httptest.NewServer(cs)
+	defer server.Close()
+
+	_, _, results := newTestEndpoint(t, server.URL)
+	go func() {
+		for range results {
+		}
+	}()
+	pool := globalCheckPool
+	globalCheckPool = newCheckPool(3)
+	defer func() { globalCheckPool = pool }()
+
+	// Every Endpoint is due on each tick of the Feed Checker, but the checks take long enough that the pools are full
+	// and some Endpoints are still being checked when the next tick comes.
+	s, _ := parseSchedule("1s", "")
+	var apps []*Application
+	for _, appKey := range []string{"app1", "app2"} {
+		a := &Application{Key: appKey, Name: appKey, cancel: make(chan bool), rwMu: &sync.RWMutex{}, pool: newCheckPool(2), data: make(map[string]interface{})}
+		for i := 0; i < 4; i++ {
+			transport, _ := newTransport(nil, nil)
+			key := fmt.Sprintf("feed%d", i)
+			a.Endpoints = append(a.Endpoints, &Endpoint{Key: key, Name: key, URL: server.URL + "/" + appKey + "/" + key,
+				Method: http.MethodGet, Timeout: 5 * time.Second, Schedule: s, transport: transport})
+		}
+		apps = append(apps, a)
+	}
+
+	var wg sync.WaitGroup
+	for _, a := range apps {
+		a.startFeedMonitor(&wg)
+	}
+	time.Sleep(3500 * time.Millisecond)
+	for _, a := range apps {
+		a.stopFeedMonitor()
+	}
+	wg.Wait()
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.peak[""] != 3 {
+		t.Errorf("Expected the global limit of 3 concurrent checks to be reached but the peak was %d", cs.peak[""])
+	}
+	for _, a := range apps {
+		if cs.peak[a.Key] > 2 {
+			t.Errorf("Expected at most 2 concurrent checks for %v but the peak was %d", a.Key, cs.peak[a.Key])
+		}
+		for _, e := range a.Endpoints {
+			path := a.Key + "/" + e.Key
+			if cs.peak[path] != 1 {
+				t.Errorf("Expected %v to be checked one at a time but the peak was %d", path, cs.peak[path])
+			}
+			if cs.completed[path] == 0 {
+				t.Errorf("Expected %v to be checked.", path)
+			}
+			if e.checking {
+				t.Errorf("Expected %v to not be checking after the Feed Checker stopped.", path)
+			}
+		}
+	}
+}
+
+func TestTryAcquireSlots(t *testing.T) {
+
+	pool := globalCheckPool
+	globalCheckPool = newCheckPool(1)
+	defer func() { globalCheckPool = pool }()
+
+	a := &Application{pool: newCheckPool(2)}
+	b := &Application{pool: newCheckPool(2)}
+	if !a.tryAcquireSlots() {
+		t.Fatal("Expected a slot when both pools are free.")
+	}
+	// The global pool is full, so the application slot taken by b must be returned.
+	if b.tryAcquireSlots() || len(b.pool.slots) != 0 {
+		t.Errorf("Expected no slot when the global pool is full, with %d application slots held", len(b.pool.slots))
+	}
+	a.releaseSlots()
+	if len(a.pool.slots) != 0 || len(globalCheckPool.slots) != 0 {
+		t.Error("Expected the slots to be released.")
+	}
+
+	cancel := make(chan bool)
+	globalCheckPool.acquire(cancel)
+	close(cancel)
+	if globalCheckPool.acquire(cancel) {
+		t.Error("Expected acquire to give up when cancelled with the pool full.")
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// proxyDirect is the proxy URL used by an Endpoint to bypass the proxy of its application.
+const proxyDirect = "direct"
+
+// defaultPorts are the ports used by URLs that do not specify one, so NoProxy entries with a port can match them.
+var defaultPorts = map[string]string{"http": "80", "https": "443"}
+
+// ProxyConfig represents the proxy used when connecting to an Endpoint.
+type ProxyConfig struct {
+	URL      string   // Proxy URL with an http, https or socks5 scheme, or direct to connect without a proxy.
+	Username string   // Optional proxy credentials.
+	Password string   // Optional proxy credentials.
+	NoProxy  []string // Hosts connected to directly: host names, domains (.example.com), IP addresses, CIDR ranges or *.
+}
+
+// merge returns the proxy settings o if they are defined, otherwise p.
+func (p *ProxyConfig) merge(o *ProxyConfig) *ProxyConfig {
+	if o != nil {
+		return o
+	}
+	return p
+}
+
+// proxyFunc returns the function used by the http.Transport to select the proxy for a request.
+func (p *ProxyConfig) proxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	if strings.EqualFold(p.URL, proxyDirect) {
+		return nil, nil
+	}
+
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url: %v", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %v, expected http, https or socks5", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy url %v has no host", p.URL)
+	}
+	if p.Username != "" {
+		u.User = url.UserPassword(p.Username, p.Password)
+		registerSecret(p.Password)
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		if p.bypass(req.URL) {
+			return nil, nil
+		}
+		return u, nil
+	}, nil
+}
+
+// bypass returns true if the URL matches the NoProxy list.
+func (p *ProxyConfig) bypass(u *url.URL) bool {
+	host := u.Hostname()
+	ip := net.ParseIP(host)
+	port := u.Port()
+	if port == "" {
+		port = defaultPorts[strings.ToLower(u.Scheme)]
+	}
+
+	for _, entry := range p.NoProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "*" {
+			return true
+		}
+
+		// Entries with a port only match that port.
+		if h, entryPort, err := net.SplitHostPort(entry); err == nil {
+			if entryPort != port {
+				continue
+			}
+			entry = h
+		}
+
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if entryIP := net.ParseIP(entry); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+
+		domain := strings.TrimPrefix(entry, ".")
+		host = strings.ToLower(host)
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyURL returns the proxy the transport uses for the request, without credentials, or an empty string if the
+// request is sent directly.
+func proxyURL(transport *http.Transport, req *http.Request) string {
+	if transport.Proxy == nil {
+		return ""
+	}
+	u, err := transport.Proxy(req)
+	if err != nil || u == nil {
+		return ""
+	}
+	redacted := *u
+	redacted.User = nil
+	return redacted.String()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestProxyBypass(t *testing.T) {
+
+	p := &ProxyConfig{
+		URL:      "http://proxy.example.com:3128",
+		Username: "user",
+		Password: "pass",
+		NoProxy:  []string{".internal.example.com", "example.org", "10.0.0.0/8", "192.168.1.1", "localhost:8080", "secure.example.com:443", "plain.example.com:80"},
+	}
+	proxy, err := p.proxyFunc()
+	if err != nil {
+		t.Fatalf("Unexpected error creating the proxy: %v", err)
+	}
+
+	tests := []struct {
+		url    string
+		direct bool
+	}{
+		{"https://feeds.internal.example.com/feed", true},
+		{"https://internal.example.com/feed", true},
+		{"https://www.example.org/feed", true},
+		{"https://notexample.org/feed", false},
+		{"http://10.1.2.3/feed", true},
+		{"http://192.168.1.1/feed", true},
+		{"http://192.168.1.2/feed", false},
+		{"http://localhost:8080/feed", true},
+		{"http://localhost:9090/feed", false},
+		{"https://www.example.com/feed", false},
+		{"https://secure.example.com/feed", true},
+		{"https://secure.example.com:443/feed", true},
+		{"http://secure.example.com/feed", false},
+		{"http://plain.example.com/feed", true},
+		{"https://plain.example.com/feed", false},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest("GET", test.url, nil)
+		u, err := proxy(req)
+		if err != nil {
+			t.Errorf("Unexpected error selecting the proxy for %v: %v", test.url, err)
+			continue
+		}
+		if test.direct != (u == nil) {
+			t.Errorf("Expected direct %v for %v but got proxy %v", test.direct, test.url, u)
+		}
+	}
+
+	transport, _ := newTransport(nil, p)
+	req, _ := http.NewRequest("GET", "https://www.example.com/feed", nil)
+	if recorded := proxyURL(transport, req); recorded != "http://proxy.example.com:3128" {
+		t.Errorf("Expected the proxy to be recorded without credentials but got %v", recorded)
+	}
+
+	if _, err = (&ProxyConfig{URL: "ftp://proxy.example.com"}).proxyFunc(); err == nil {
+		t.Error("Expected an error for an unsupported proxy scheme.")
+	}
+	if transport, _ = newTransport(nil, &ProxyConfig{URL: "direct"}); transport.Proxy != nil {
+		t.Error("Expected no proxy for a direct connection.")
+	}
+}
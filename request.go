@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// sensitiveHeaders are request headers whose values are never stored.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":        true,
+	"Proxy-Authorization":  true,
+	"Cookie":               true,
+	"X-Api-Key":            true,
+	"X-Auth-Token":         true,
+	"X-Amz-Security-Token": true,
+}
+
+// sensitiveHeaderWords mark any request header containing them as sensitive.
+var sensitiveHeaderWords = []string{"token", "secret", "password", "apikey", "api-key", "signature"}
+
+// RequestInfo contains the request that was sent to produce an EndpointResult, with sensitive values redacted.
+type RequestInfo struct {
+	Method  string
+	URL     string
+	Headers map[string][]string
+	Body    string
+}
+
+// newRequestInfo captures the method, URL, headers and body of the request as it will be sent.
+func newRequestInfo(req *http.Request, body string) *RequestInfo {
+	ri := &RequestInfo{
+		Method:  req.Method,
+		URL:     redactURL(req.URL),
+		Headers: make(map[string][]string),
+		Body:    redactSecrets(body),
+	}
+
+	for k, v := range req.Header {
+		values := make([]string, len(v))
+		for i, hv := range v {
+			if isSensitiveHeader(k) {
+				values[i] = redactedValue
+			} else {
+				values[i] = redactValue(hv)
+			}
+		}
+		ri.Headers[k] = values
+	}
+	return ri
+}
+
+// isSensitiveHeader returns true if the value of the header should not be stored.
+func isSensitiveHeader(name string) bool {
+	if sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+		return true
+	}
+	lower := strings.ToLower(name)
+	for _, w := range sensitiveHeaderWords {
+		if strings.Contains(lower, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// Curl returns a curl command line that reproduces the request.
+func (ri *RequestInfo) Curl() string {
+	parts := []string{"curl", "-X", ri.Method, shellQuote(ri.URL)}
+
+	names := make([]string, 0, len(ri.Headers))
+	for k := range ri.Headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		for _, v := range ri.Headers[k] {
+			parts = append(parts, "-H", shellQuote(k+": "+v))
+		}
+	}
+
+	if ri.Body != "" {
+		parts = append(parts, "--data-raw", shellQuote(ri.Body))
+	}
+	return strings.Join(parts, " ")
+}
+
+// shellQuote quotes s for use as a single POSIX shell argument.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sample modes define how the dynamic URLs checked in each cycle are chosen when an Endpoint samples its URLs.
+const (
+	SampleModeRotate = "rotate" // Check the URLs that were checked least recently.
+	SampleModeRandom = "random" // Check a random selection of URLs.
+)
+
+func validateSampleMode(mode string) (string, error) {
+	switch strings.ToLower(mode) {
+	case "", SampleModeRotate:
+		return SampleModeRotate, nil
+	case SampleModeRandom:
+		return SampleModeRandom, nil
+	}
+	return "", fmt.Errorf("unknown samplemode %v", mode)
+}
+
+// sampleURLs returns the URLs to check in this cycle and the number of URLs that are outside the coverage window.
+// URLs that have not been checked within the coverage window, measured from when they first appeared if they have
+// never been checked, are checked first, oldest first. The sample size is never exceeded, so a coverage window that
+// cannot be met leaves URLs overdue rather than fetching all of them. The remaining URLs are chosen according to the
+// sample mode. The caller must hold the application lock.
+func (e *Endpoint) sampleURLs(urls []string, now time.Time) ([]string, int) {
+	for _, url := range urls {
+		if _, ok := e.urlFirstSeen[url]; !ok {
+			e.urlFirstSeen[url] = now
+		}
+	}
+
+	if e.Sample <= 0 || len(urls) <= e.Sample {
+		return urls, 0
+	}
+
+	var overdue, remaining []string
+	for _, url := range urls {
+		if e.CoverageWindow > 0 && e.lastURLCheck(url).Add(e.CoverageWindow).Before(now) {
+			overdue = append(overdue, url)
+		} else {
+			remaining = append(remaining, url)
+		}
+	}
+
+	if len(overdue) >= e.Sample {
+		sort.SliceStable(overdue, func(i, j int) bool {
+			return e.lastURLCheck(overdue[i]).Before(e.lastURLCheck(overdue[j]))
+		})
+		return overdue[:e.Sample], len(overdue)
+	}
+
+	if e.SampleMode == SampleModeRandom {
+		rand.Shuffle(len(remaining), func(i, j int) { remaining[i], remaining[j] = remaining[j], remaining[i] })
+	} else {
+		// URLs that have never been checked have a zero check time, so they are checked first.
+		checked := func(url string) time.Time {
+			if s, ok := e.currentURLStatus[url]; ok {
+				return s.CheckTime
+			}
+			return time.Time{}
+		}
+		sort.SliceStable(remaining, func(i, j int) bool {
+			return checked(remaining[i]).Before(checked(remaining[j]))
+		})
+	}
+
+	return append(overdue, remaining[:e.Sample-len(overdue)]...), len(overdue)
+}
+
+// lastURLCheck returns when the URL was last checked, or when it first appeared if it has not been checked yet.
+func (e *Endpoint) lastURLCheck(url string) time.Time {
+	if s, ok := e.currentURLStatus[url]; ok {
+		return s.CheckTime
+	}
+	return e.urlFirstSeen[url]
+}
+
+// pruneURLFirstSeen forgets when URLs that are no longer current first appeared. The caller must hold the
+// application lock.
+func (e *Endpoint) pruneURLFirstSeen() {
+	current := make(map[string]bool, len(e.CurrentURLs))
+	for _, url := range e.CurrentURLs {
+		current[url] = true
+	}
+	for url := range e.urlFirstSeen {
+		if !current[url] {
+			delete(e.urlFirstSeen, url)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSampleURLsRotate(t *testing.T) {
+
+	now := time.Now()
+	e := &Endpoint{
+		Sample:           2,
+		SampleMode:       SampleModeRotate,
+		currentURLStatus: make(map[string]*URLStatus),
+		urlFirstSeen:     make(map[string]time.Time),
+	}
+	urls := []string{"a", "b", "c", "d"}
+
+	// Each cycle checks the URLs that were checked least recently, so every URL is checked in two cycles.
+	checked := make(map[string]bool)
+	for cycle := 0; cycle < 2; cycle++ {
+		sample, _ := e.sampleURLs(urls, now)
+		if len(sample) != 2 {
+			t.Fatalf("Expected a sample of 2 URLs but got %v", sample)
+		}
+		for _, url := range sample {
+			checked[url] = true
+			e.currentURLStatus[url] = &URLStatus{URL: url, CheckTime: now}
+		}
+		now = now.Add(time.Minute)
+	}
+	if len(checked) != 4 {
+		t.Errorf("Expected every URL to be checked after two cycles but got %v", checked)
+	}
+}
+
+func TestSampleURLsCoverageWindow(t *testing.T) {
+
+	now := time.Now()
+	e := &Endpoint{
+		Sample:           1,
+		SampleMode:       SampleModeRandom,
+		CoverageWindow:   time.Hour,
+		currentURLStatus: make(map[string]*URLStatus),
+		urlFirstSeen:     make(map[string]time.Time),
+	}
+	urls := []string{"a", "b", "c"}
+
+	e.sampleURLs(urls, now)
+	e.currentURLStatus["a"] = &URLStatus{URL: "a", CheckTime: now.Add(-2 * time.Hour)}
+	e.currentURLStatus["b"] = &URLStatus{URL: "b", CheckTime: now.Add(20 * time.Minute)}
+
+	sample, overdue := e.sampleURLs(urls, now.Add(30*time.Minute))
+	if !reflect.DeepEqual(sample, []string{"a"}) || overdue != 1 {
+		t.Errorf("Expected the URL outside the coverage window to be sampled but got %v", sample)
+	}
+
+	// c has not been checked since it appeared more than an hour ago, but a was checked longer ago and the sample
+	// is not exceeded.
+	sample, overdue = e.sampleURLs(urls, now.Add(70*time.Minute))
+	if !reflect.DeepEqual(sample, []string{"a"}) || overdue != 2 {
+		t.Errorf("Expected only the oldest of 2 overdue URLs to be sampled but got %v of %d", sample, overdue)
+	}
+	e.CurrentURLs = urls
+	e.urlFirstSeen["c"] = time.Now().Add(-70 * time.Minute)
+	if statuses := e.URLStatuses(); !statuses[2].Overdue {
+		t.Errorf("Expected the URL left out of the sample to be reported as overdue but got %+v", statuses[2])
+	}
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule determines when an Endpoint is checked, either at a fixed interval or at the times matching a cron
+// expression in a time zone.
+type Schedule struct {
+	Interval time.Duration  // Time between checks when the schedule is not a cron expression.
+	Cron     string         // Cron expression, ex: 5 2 * * *
+	Location *time.Location // Time zone the cron expression is evaluated in.
+	phase    time.Duration
+	cron     *cronSchedule
+}
+
+// parseSchedule parses a check interval, which can be a number of minutes, a duration such as 15s, or a cron
+// expression evaluated in the named time zone.
+func parseSchedule(value string, timeZone string) (*Schedule, error) {
+	s := &Schedule{Location: time.Local}
+	if timeZone != "" {
+		loc, err := time.LoadLocation(timeZone)
+		if err != nil {
+			return nil, fmt.Errorf("unknown time zone %v: %v", timeZone, err)
+		}
+		s.Location = loc
+	}
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return s, nil
+	}
+
+	if minutes, err := strconv.Atoi(value); err == nil {
+		if minutes < 0 {
+			return nil, fmt.Errorf("check interval %v is negative", value)
+		}
+		s.Interval = time.Duration(minutes) * time.Minute
+		return s, nil
+	}
+
+	if d, err := time.ParseDuration(value); err == nil {
+		if d < time.Second {
+			return nil, fmt.Errorf("check interval %v is less than the minimum of 1s", value)
+		}
+		s.Interval = d
+		return s, nil
+	}
+
+	c, err := parseCron(value)
+	if err != nil {
+		return nil, fmt.Errorf("check interval %v is not a number of minutes, a duration or a cron expression: %v", value, err)
+	}
+	s.Cron = value
+	s.cron = c
+	if s.next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("cron expression %v never matches", value)
+	}
+	return s, nil
+}
+
+// spread offsets an interval schedule by a phase derived from the key, so Endpoints with the same interval are not all
+// checked at the same time. The phase is stable, so the cadence is the same across restarts.
+func (s *Schedule) spread(key string) {
+	if s.cron != nil || s.Interval == 0 {
+		return
+	}
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	s.phase = time.Duration(h.Sum64() % uint64(s.Interval))
+}
+
+// next returns the first scheduled time after t. Interval schedules run at fixed multiples of the interval, offset by
+// the phase, rather than relative to t.
+func (s *Schedule) next(t time.Time) time.Time {
+	if s.cron != nil {
+		return s.cron.next(t.In(s.Location))
+	}
+	if s.Interval == 0 {
+		return t
+	}
+	return t.Add(-s.phase).Truncate(s.Interval).Add(s.phase + s.Interval)
+}
+
+// catchUpSpread is the longest a check missed while the service was stopped is delayed, so a restart does not check
+// every Endpoint at once.
+const catchUpSpread = 5 * time.Minute
+
+// firstCheck returns the time of the first check after starting at now, given the time of the last check before the
+// service stopped. A scheduled check that was missed while stopped is made up soon after starting, offset by the phase
+// of the schedule up to catchUpSpread. Otherwise the first check is the next scheduled time.
+func (s *Schedule) firstCheck(last time.Time, now time.Time) time.Time {
+	next := s.next(now)
+	if last.IsZero() || !s.next(last).Before(now) {
+		return next
+	}
+	spread := catchUpSpread
+	if s.Interval > 0 && s.Interval < spread {
+		spread = s.Interval
+	}
+	if catchUp := now.Add(s.phase % spread); catchUp.Before(next) {
+		return catchUp
+	}
+	return next
+}
+
+// jitter returns a random delay of up to max.
+func jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+// upcoming returns the n scheduled times starting at t.
+func (s *Schedule) upcoming(t time.Time, n int) []time.Time {
+	if s.cron == nil && s.Interval == 0 {
+		return nil
+	}
+	times := []time.Time{t.In(s.Location)}
+	for len(times) < n {
+		t = s.next(t)
+		if t.IsZero() {
+			break
+		}
+		times = append(times, t.In(s.Location))
+	}
+	return times
+}
+
+func (s *Schedule) String() string {
+	if s.cron != nil {
+		return fmt.Sprintf("%v (%v)", s.Cron, s.Location)
+	}
+	if s.Interval == 0 {
+		return "Continuous"
+	}
+	return fmt.Sprintf("Every %v", s.Interval)
+}
+
+// cronSchedule is a parsed five field cron expression. Each field is a bit set of the matching values.
+type cronSchedule struct {
+	minute  uint64
+	hour    uint64
+	dom     uint64
+	month   uint64
+	dow     uint64
+	domStar bool
+	dowStar bool
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cronMonths = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var cronWeekdays = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+
+// parseCron parses a cron expression with minute, hour, day of month, month and day of week fields, or one of the
+// descriptors @yearly, @monthly, @weekly, @daily and @hourly.
+func parseCron(expr string) (*cronSchedule, error) {
+	if d, ok := cronDescriptors[strings.ToLower(strings.TrimSpace(expr))]; ok {
+		expr = d
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields but found %v", len(fields))
+	}
+
+	c := &cronSchedule{domStar: strings.HasPrefix(fields[2], "*"), dowStar: strings.HasPrefix(fields[4], "*")}
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("minute: %v", err)
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("hour: %v", err)
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("day of month: %v", err)
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12, cronMonths); err != nil {
+		return nil, fmt.Errorf("month: %v", err)
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7, cronWeekdays); err != nil {
+		return nil, fmt.Errorf("day of week: %v", err)
+	}
+	// Both 0 and 7 are Sunday.
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	return c, nil
+}
+
+// parseCronField parses a comma separated list of values, ranges and steps, ex: 1,15 or 9-17 or */15
+func parseCronField(field string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %v", part)
+			}
+			rangePart = part[:i]
+		}
+
+		lo, hi := min, max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if lo, err = cronValue(bounds[0], names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = cronValue(bounds[1], names); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%v is out of range %v-%v", part, min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func cronValue(s string, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %v", s)
+	}
+	return v, nil
+}
+
+// next returns the first matching minute after t, in the location of t, or the zero time if there is no match within
+// five years.
+func (c *cronSchedule) next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Add(time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	limit := t.AddDate(5, 0, 0)
+
+	// Midnight can fall in a daylight saving time gap, in which case time.Date resolves it to the previous day and the
+	// hour is added to move past the gap.
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			next := time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			if next.Month() == t.Month() {
+				next = next.Add(time.Hour)
+			}
+			t = next
+			continue
+		}
+		if !c.dayMatches(t) {
+			next := time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			if next.Day() == t.Day() {
+				next = next.Add(time.Hour)
+			}
+			t = next
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			// Add the remaining minutes rather than building the next hour, which may not exist on days with a daylight
+			// saving time change.
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches follows the cron convention that when both the day of month and day of week are restricted, a day
+// matching either field matches.
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// validateFailingInterval checks the failing interval is shorter than the interval of the schedule, and returns the
+// number of recovery passes with the default applied.
+func validateFailingInterval(failingInterval time.Duration, recoveryPasses int, s *Schedule) (int, error) {
+	if failingInterval == 0 {
+		return 0, nil
+	}
+	if failingInterval < time.Second {
+		return 0, fmt.Errorf("failing interval %v is less than the minimum of 1s", failingInterval)
+	}
+	if s.cron == nil && s.Interval > 0 && failingInterval >= s.Interval {
+		return 0, fmt.Errorf("failing interval %v is not shorter than the check interval %v", failingInterval, s.Interval)
+	}
+	if recoveryPasses < 0 {
+		return 0, fmt.Errorf("recoverypasses must not be negative")
+	}
+	if recoveryPasses == 0 {
+		recoveryPasses = 1
+	}
+	return recoveryPasses, nil
+}
+
+// updateScheduleMode switches the Endpoint to its failing interval when a check fails, and back to its schedule after
+// RecoveryPasses consecutive passing checks. The caller must hold the application lock.
+func (e *Endpoint) updateScheduleMode() {
+	if e.FailingInterval == 0 {
+		return
+	}
+
+	switch e.CurrentStatus {
+	case StatusFail:
+		e.Passes = 0
+		if !e.Failing {
+			// The next check was scheduled with the normal schedule when this check started.
+			e.Failing = true
+			if next := e.lastCheckTime.Add(e.FailingInterval); next.Before(e.nextCheckTime) {
+				e.nextCheckTime = next
+			}
+		}
+	case StatusOK:
+		if !e.Failing {
+			return
+		}
+		e.Passes++
+		if e.Passes >= e.RecoveryPasses {
+			e.Failing = false
+			e.Passes = 0
+			e.nextCheckTime = e.Schedule.next(e.lastCheckTime).Add(jitter(e.Jitter))
+		}
+	}
+}
+
+// restoreLastCheck returns the time of the most recent result stored for the Endpoint, or the zero time if it has not
+// been checked. The current URLs of a dynamic Endpoint and the last result of each URL are restored as well, so the
+// URL status and sampling coverage carry over a restart.
+func (a *Application) restoreLastCheck(e *Endpoint) time.Time {
+	if !e.Dynamic {
+		epr, _ := GetLastEndpointResult(a.Key, e.Key, e.URL)
+		if epr == nil {
+			return time.Time{}
+		}
+		return epr.CheckTime
+	}
+
+	urls, _ := GetDynamicURLs(a.Key, e.Key)
+	var last time.Time
+	for _, du := range urls {
+		if du.Retired {
+			continue
+		}
+		e.CurrentURLs = append(e.CurrentURLs, du.URL)
+		e.CurrentLabels[du.URL] = du.Label
+		e.urlFirstSeen[du.URL] = du.FirstSeen
+
+		epr, _ := GetLastEndpointResult(a.Key, e.Key, du.URL)
+		if epr == nil {
+			continue
+		}
+		e.setURLStatus(epr)
+		if epr.CheckTime.After(last) {
+			last = epr.CheckTime
+		}
+	}
+	return last
+}
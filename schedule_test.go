@@ -0,0 +1,190 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+
+	tests := []struct {
+		value    string
+		interval time.Duration
+		cron     bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Minute, false},
+		{"15s", 15 * time.Second, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"5 2 * * *", 0, true},
+		{"@hourly", 0, true},
+	}
+	for _, test := range tests {
+		s, err := parseSchedule(test.value, "UTC")
+		if err != nil {
+			t.Errorf("Unexpected error parsing %v: %v", test.value, err)
+			continue
+		}
+		if s.Interval != test.interval || (s.Cron != "") != test.cron {
+			t.Errorf("Unexpected schedule for %v: %v", test.value, s)
+		}
+	}
+
+	for _, value := range []string{"-1", "500ms", "5 2 * *", "60 * * * *", "* * 31 2 *", "*/0 * * * *", "0 0 * * funday"} {
+		if _, err := parseSchedule(value, ""); err == nil {
+			t.Errorf("Expected an error parsing %v", value)
+		}
+	}
+	if _, err := parseSchedule("5 2 * * *", "Mars/Olympus_Mons"); err == nil {
+		t.Error("Expected an error for an unknown time zone.")
+	}
+}
+
+func TestCronNext(t *testing.T) {
+
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Skipf("Time zone data unavailable: %v", err)
+	}
+
+	tests := []struct {
+		cron     string
+		from     time.Time
+		expected time.Time
+	}{
+		{"5 2 * * *", time.Date(2018, 3, 1, 1, 0, 0, 0, chicago), time.Date(2018, 3, 1, 2, 5, 0, 0, chicago)},
+		{"5 2 * * *", time.Date(2018, 3, 1, 2, 5, 0, 0, chicago), time.Date(2018, 3, 2, 2, 5, 0, 0, chicago)},
+		{"*/15 9-17 * * mon-fri", time.Date(2018, 3, 2, 17, 50, 0, 0, chicago), time.Date(2018, 3, 5, 9, 0, 0, 0, chicago)},
+		{"0 0 1,15 * *", time.Date(2018, 3, 2, 0, 0, 0, 0, chicago), time.Date(2018, 3, 15, 0, 0, 0, 0, chicago)},
+		{"0 12 1 * 7", time.Date(2018, 3, 2, 0, 0, 0, 0, chicago), time.Date(2018, 3, 4, 12, 0, 0, 0, chicago)},
+		{"0 0 29 feb *", time.Date(2018, 3, 1, 0, 0, 0, 0, chicago), time.Date(2020, 2, 29, 0, 0, 0, 0, chicago)},
+		// 02:30 does not exist on the day daylight saving time starts.
+		{"30 2 * * *", time.Date(2018, 3, 11, 0, 0, 0, 0, chicago), time.Date(2018, 3, 12, 2, 30, 0, 0, chicago)},
+	}
+
+	for _, test := range tests {
+		s, err := parseSchedule(test.cron, "America/Chicago")
+		if err != nil {
+			t.Errorf("Unexpected error parsing %v: %v", test.cron, err)
+			continue
+		}
+		if next := s.next(test.from); !next.Equal(test.expected) {
+			t.Errorf("Expected %v after %v for %v but got %v", test.expected, test.from, test.cron, next)
+		}
+	}
+
+	s, _ := parseSchedule("0 */6 * * *", "America/Chicago")
+	upcoming := s.upcoming(time.Date(2018, 3, 1, 0, 0, 0, 0, chicago), 3)
+	if len(upcoming) != 3 || upcoming[2].Hour() != 12 {
+		t.Errorf("Unexpected upcoming checks: %v", upcoming)
+	}
+}
+
+func TestScheduleSpread(t *testing.T) {
+
+	a, _ := parseSchedule("5m", "")
+	a.spread("app/mainfeed")
+	b, _ := parseSchedule("5m", "")
+	b.spread("app/mainfeed")
+	c, _ := parseSchedule("5m", "")
+	c.spread("app/secondaryfeed")
+
+	if a.phase != b.phase {
+		t.Errorf("Expected the same phase for the same key but got %v and %v", a.phase, b.phase)
+	}
+	if a.phase == c.phase {
+		t.Errorf("Expected different phases for different keys but both are %v", a.phase)
+	}
+
+	now := time.Now()
+	next := a.next(now)
+	if !next.After(now) || next.Sub(now) > 5*time.Minute {
+		t.Errorf("Expected the next check within one interval of %v but got %v", now, next)
+	}
+	// A check that starts late, or is delayed by jitter, keeps the cadence.
+	if following := a.next(next.Add(90 * time.Second)); following.Sub(next) != 5*time.Minute {
+		t.Errorf("Expected the following check 5m after %v but got %v", next, following)
+	}
+	if jitter(0) != 0 {
+		t.Error("Expected no jitter when none is configured.")
+	}
+	if j := jitter(time.Second); j < 0 || j >= time.Second {
+		t.Errorf("Expected jitter less than 1s but got %v", j)
+	}
+}
+
+func TestScheduleFirstCheck(t *testing.T) {
+
+	daily, _ := parseSchedule("1440", "")
+	daily.spread("app/mainfeed")
+	// Start half a day before a scheduled check, so the check that is made up is always before it.
+	next := daily.next(time.Now())
+	now := next.Add(-12 * time.Hour)
+
+	if first := daily.firstCheck(time.Time{}, now); !first.Equal(next) {
+		t.Errorf("Expected the first check at the next scheduled time %v without a previous check but got %v", next, first)
+	}
+	if first := daily.firstCheck(next.Add(-24*time.Hour).Add(time.Minute), now); !first.Equal(next) {
+		t.Errorf("Expected the first check at the next scheduled time %v when none was missed but got %v", next, first)
+	}
+	// The scheduled check before now was missed, so it is made up within the catch up spread.
+	first := daily.firstCheck(next.Add(-48*time.Hour).Add(time.Minute), now)
+	if first.Before(now) || first.Sub(now) >= catchUpSpread {
+		t.Errorf("Expected the missed check to be made up within %v of %v but got %v", catchUpSpread, now, first)
+	}
+	if first.Sub(now) != daily.phase%catchUpSpread {
+		t.Errorf("Expected the missed check to be offset by the phase but got %v", first.Sub(now))
+	}
+
+	cron, _ := parseSchedule("0 2 * * *", "")
+	if first := cron.firstCheck(now.Add(-49*time.Hour), now); !first.Equal(now) {
+		t.Errorf("Expected a missed cron run to be made up immediately but got %v", first)
+	}
+}
+
+func TestUpdateScheduleMode(t *testing.T) {
+
+	s, _ := parseSchedule("10m", "")
+	e := &Endpoint{Schedule: s, FailingInterval: 30 * time.Second, RecoveryPasses: 2}
+	// Start a full interval before the next check, as the grid time from scheduleNextCheck may be only seconds away.
+	e.lastCheckTime = time.Now()
+	e.nextCheckTime = e.lastCheckTime.Add(10 * time.Minute)
+
+	e.CurrentStatus = StatusFail
+	e.updateScheduleMode()
+	if !e.Failing || e.nextCheckTime.Sub(e.lastCheckTime) != 30*time.Second {
+		t.Fatalf("Expected the failing interval after a failure but the next check is in %v", e.nextCheckTime.Sub(e.lastCheckTime))
+	}
+	e.scheduleNextCheck()
+	if e.nextCheckTime.Sub(e.lastCheckTime) != 30*time.Second {
+		t.Errorf("Expected checks every 30s while failing but the next check is in %v", e.nextCheckTime.Sub(e.lastCheckTime))
+	}
+
+	e.CurrentStatus = StatusOK
+	e.updateScheduleMode()
+	if !e.Failing || e.Passes != 1 {
+		t.Errorf("Expected the failing interval to remain after one pass, failing %v with %v passes", e.Failing, e.Passes)
+	}
+	e.CurrentStatus = StatusFail
+	e.updateScheduleMode()
+	if e.Passes != 0 {
+		t.Errorf("Expected a failure to reset the passes but got %v", e.Passes)
+	}
+
+	e.CurrentStatus = StatusOK
+	e.updateScheduleMode()
+	e.updateScheduleMode()
+	if e.Failing {
+		t.Error("Expected the normal schedule after two consecutive passes.")
+	}
+	if !e.nextCheckTime.Equal(s.next(e.lastCheckTime)) {
+		t.Errorf("Expected the next check on the normal schedule at %v but got %v", s.next(e.lastCheckTime), e.nextCheckTime)
+	}
+
+	if _, err := validateFailingInterval(10*time.Minute, 0, s); err == nil {
+		t.Error("Expected an error for a failing interval that is not shorter than the check interval.")
+	}
+	if passes, _ := validateFailingInterval(time.Minute, 0, s); passes != 1 {
+		t.Errorf("Expected 1 recovery pass by default but got %v", passes)
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// minSecretLength is the shortest secret that is redacted wherever it appears. Shorter values would redact unrelated
+// text, so they are only redacted where they are a whole value, such as a header value or query parameter.
+const minSecretLength = 6
+
+const redactedValue = "******"
+
+// configReferenceRegex matches ${ENV_VAR} and ${file:/path/to/secret} references, and the $${ escape for a literal ${.
+var configReferenceRegex = regexp.MustCompile(`\$\$\{|\$\{([^}]*)\}`)
+
+var secrets = make(map[string]bool)
+var shortSecrets = make(map[string]bool)
+var secretsMu = &sync.RWMutex{}
+
+// secretEscapeTemplates write a value the way the web templates escape it in HTML text, URL paths and URL queries.
+var secretEscapeTemplates = htmltemplate.Must(htmltemplate.New("secret").Parse(
+	`{{define "text"}}{{.}}{{end}}{{define "path"}}<a href="/{{.}}">{{end}}{{define "query"}}<a href="?q={{.}}">{{end}}`))
+
+// expandConfigReferences replaces ${ENV_VAR} and ${file:/path/to/secret} references in the values of the configuration
+// with their values. The configuration is decoded first and the expanded values are encoded again, so a value cannot
+// change the structure of the configuration and references in comments are ignored. The resolved values are registered
+// as secrets so they are redacted from logs and the web interface. The references that resolve to values shorter than
+// minSecretLength are returned, as those values are only redacted where they are a whole value.
+func expandConfigReferences(b []byte) ([]byte, []string, error) {
+	var doc yaml.MapSlice
+	err := yaml.Unmarshal(b, &doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var unresolved []string
+	var resolved []string
+	var short []string
+
+	expand := func(m string) string {
+		if m == "$${" {
+			return "${"
+		}
+
+		ref := strings.TrimSpace(m[2 : len(m)-1])
+		if strings.HasPrefix(ref, "file:") {
+			path := strings.TrimPrefix(ref, "file:")
+			v, err := ioutil.ReadFile(path)
+			if err != nil {
+				unresolved = append(unresolved, fmt.Sprintf("unable to read secret file %v", path))
+				return m
+			}
+			value := strings.TrimRight(string(v), "\r\n")
+			resolved = append(resolved, value)
+			if len(value) < minSecretLength {
+				short = append(short, m)
+			}
+			return value
+		}
+
+		value, ok := os.LookupEnv(ref)
+		if !ok {
+			unresolved = append(unresolved, fmt.Sprintf("environment variable %v is not set", ref))
+			return m
+		}
+		resolved = append(resolved, value)
+		if len(value) < minSecretLength {
+			short = append(short, m)
+		}
+		return value
+	}
+
+	expanded := expandConfigValue(doc, expand)
+
+	if len(unresolved) > 0 {
+		return nil, nil, fmt.Errorf("unresolved configuration references: %v", strings.Join(unresolved, ", "))
+	}
+
+	for _, v := range resolved {
+		registerSecret(v)
+	}
+
+	b, err = yaml.Marshal(expanded)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b, short, nil
+}
+
+// expandConfigValue expands the references in the string values of a decoded configuration. An expanded value that is
+// written the same way as a number or boolean is converted, so references can be used for fields of those types.
+func expandConfigValue(v interface{}, expand func(string) string) interface{} {
+	switch v := v.(type) {
+	case yaml.MapSlice:
+		for i := range v {
+			v[i].Value = expandConfigValue(v[i].Value, expand)
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = expandConfigValue(v[i], expand)
+		}
+		return v
+	case string:
+		if !configReferenceRegex.MatchString(v) {
+			return v
+		}
+		s := configReferenceRegex.ReplaceAllStringFunc(v, expand)
+		var scalar interface{}
+		if yaml.Unmarshal([]byte(s), &scalar) == nil {
+			switch scalar.(type) {
+			case int, int64, uint64, float64, bool:
+				if b, err := yaml.Marshal(scalar); err == nil && strings.TrimSpace(string(b)) == s {
+					return scalar
+				}
+			}
+		}
+		return s
+	}
+	return v
+}
+
+// registerSecret records a value that must not be displayed in logs or the web interface. The escaped forms of the
+// value are recorded too, as the web pages are redacted after the templates have escaped them. Values shorter than
+// minSecretLength are only redacted where they are a whole value.
+func registerSecret(value string) {
+	if value == "" {
+		return
+	}
+	if len(value) < minSecretLength {
+		secretsMu.Lock()
+		shortSecrets[value] = true
+		secretsMu.Unlock()
+		return
+	}
+	forms := escapedSecretForms(value)
+	secretsMu.Lock()
+	secrets[value] = true
+	for _, f := range forms {
+		secrets[f] = true
+	}
+	secretsMu.Unlock()
+}
+
+// escapedSecretForms returns the ways the value can be written by the web templates, in URLs and in JSON.
+func escapedSecretForms(value string) []string {
+	forms := []string{url.QueryEscape(value), url.PathEscape(value)}
+	if b, err := json.Marshal(value); err == nil {
+		forms = append(forms, string(b[1:len(b)-1]))
+	}
+	for name, prefix := range map[string]string{"text": "", "path": `<a href="/`, "query": `<a href="?q=`} {
+		var buf bytes.Buffer
+		if secretEscapeTemplates.ExecuteTemplate(&buf, name, value) == nil {
+			forms = append(forms, strings.TrimSuffix(strings.TrimPrefix(buf.String(), prefix), `">`))
+		}
+	}
+	return forms
+}
+
+// redactSecrets replaces all registered secret values in s.
+func redactSecrets(s string) string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+
+	if len(secrets) == 0 {
+		return s
+	}
+
+	// Replace longer values first so a secret containing another secret is fully redacted.
+	values := make([]string, 0, len(secrets))
+	for v := range secrets {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+
+	for _, v := range values {
+		s = strings.Replace(s, v, redactedValue, -1)
+	}
+	return s
+}
+
+// redactValue redacts a whole value, such as a header value or query parameter, that is a secret of any length, and
+// the secrets that appear within it.
+func redactValue(value string) string {
+	secretsMu.RLock()
+	short := shortSecrets[value]
+	secretsMu.RUnlock()
+	if short {
+		return redactedValue
+	}
+	return redactSecrets(value)
+}
+
+// redactURL redacts the secrets in a URL, including short secrets that are the whole value of a query parameter or the
+// password of the URL.
+func redactURL(u *url.URL) string {
+	r := *u
+	if r.User != nil {
+		if p, ok := r.User.Password(); ok && redactValue(p) == redactedValue {
+			r.User = url.UserPassword(r.User.Username(), redactedValue)
+		}
+	}
+	// The parameters are redacted in place, as encoding the parsed query would change their order.
+	params := strings.Split(r.RawQuery, "&")
+	for i, param := range params {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		if v, err := url.QueryUnescape(kv[1]); err == nil && redactValue(v) == redactedValue {
+			params[i] = kv[0] + "=" + redactedValue
+		}
+	}
+	r.RawQuery = strings.Join(params, "&")
+
+	// The URL escapes the redacted password, so it is restored to match the other redacted values.
+	escaped := strings.TrimPrefix(url.UserPassword("", redactedValue).String(), ":")
+	return redactSecrets(strings.Replace(r.String(), ":"+escaped+"@", ":"+redactedValue+"@", 1))
+}
+
+// redactingFormatter removes registered secrets from formatted log entries.
+type redactingFormatter struct {
+	logrus.Formatter
+}
+
+func (f *redactingFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	b, err := f.Formatter.Format(entry)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(redactSecrets(string(b))), nil
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SigningConfig represents the config data for a request signer.
+type SigningConfig struct {
+	Type   string
+	Config map[string]interface{}
+}
+
+// Signer defines the interface that request signers need to implement. The request is signed after all its headers
+// are set, immediately before it is sent.
+type Signer interface {
+	initialize(map[string]interface{}) error
+	sign(req *http.Request, body string) error
+}
+
+// HMACSigner signs the method, URI, optional timestamp and body of a request with a shared key.
+type HMACSigner struct {
+	key             string
+	Header          string
+	Prefix          string
+	TimestampHeader string
+	BodyOnly        bool
+	Encoding        string
+	hash            func() hash.Hash
+	now             func() time.Time
+}
+
+func (s *HMACSigner) initialize(data map[string]interface{}) error {
+	var ok bool
+	if s.key, ok = data["key"].(string); !ok || s.key == "" {
+		return fmt.Errorf("hmac signing requires a key")
+	}
+	registerSecret(s.key)
+
+	s.Header = "X-Signature"
+	if h, ok := data["header"].(string); ok {
+		s.Header = h
+	}
+	s.Prefix, _ = data["prefix"].(string)
+	s.TimestampHeader, _ = data["timestampheader"].(string)
+	s.BodyOnly, _ = data["bodyonly"].(bool)
+
+	switch algorithm, _ := data["algorithm"].(string); strings.ToLower(algorithm) {
+	case "", "sha256":
+		s.hash = sha256.New
+	case "sha1":
+		s.hash = sha1.New
+	case "sha512":
+		s.hash = sha512.New
+	default:
+		return fmt.Errorf("unknown hmac algorithm %v", algorithm)
+	}
+
+	s.Encoding = "hex"
+	if e, ok := data["encoding"].(string); ok {
+		s.Encoding = strings.ToLower(e)
+	}
+	if s.Encoding != "hex" && s.Encoding != "base64" {
+		return fmt.Errorf("unknown hmac encoding %v", s.Encoding)
+	}
+
+	s.now = time.Now
+	return nil
+}
+
+// sign sets the signature header. The signed message is the body when bodyonly is set, otherwise the method, request
+// URI, timestamp (when a timestamp header is configured) and body, separated by newlines.
+func (s *HMACSigner) sign(req *http.Request, body string) error {
+	var parts []string
+	if !s.BodyOnly {
+		parts = append(parts, req.Method, req.URL.RequestURI())
+	}
+	if s.TimestampHeader != "" {
+		timestamp := strconv.FormatInt(s.now().Unix(), 10)
+		req.Header.Set(s.TimestampHeader, timestamp)
+		if !s.BodyOnly {
+			parts = append(parts, timestamp)
+		}
+	}
+	parts = append(parts, body)
+
+	mac := hmac.New(s.hash, []byte(s.key))
+	mac.Write([]byte(strings.Join(parts, "\n")))
+
+	var signature string
+	if s.Encoding == "base64" {
+		signature = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	} else {
+		signature = hex.EncodeToString(mac.Sum(nil))
+	}
+	req.Header.Set(s.Header, s.Prefix+signature)
+	return nil
+}
+
+// AWSV4Signer signs requests with AWS Signature Version 4, as used by API Gateway and other AWS services.
+type AWSV4Signer struct {
+	AccessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	Region          string
+	Service         string
+	now             func() time.Time
+}
+
+func (s *AWSV4Signer) initialize(data map[string]interface{}) error {
+	var ok bool
+	if s.AccessKeyID, ok = data["accesskeyid"].(string); !ok || s.AccessKeyID == "" {
+		return fmt.Errorf("awsv4 signing requires an accesskeyid")
+	}
+	if s.secretAccessKey, ok = data["secretaccesskey"].(string); !ok || s.secretAccessKey == "" {
+		return fmt.Errorf("awsv4 signing requires a secretaccesskey")
+	}
+	if s.Region, ok = data["region"].(string); !ok || s.Region == "" {
+		return fmt.Errorf("awsv4 signing requires a region")
+	}
+	s.Service = "execute-api"
+	if service, ok := data["service"].(string); ok {
+		s.Service = service
+	}
+	s.sessionToken, _ = data["sessiontoken"].(string)
+	registerSecret(s.secretAccessKey)
+	registerSecret(s.sessionToken)
+
+	s.now = time.Now
+	return nil
+}
+
+func (s *AWSV4Signer) sign(req *http.Request, body string) error {
+	t := s.now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+	payloadHash := sha256Hex(body)
+	if s.Service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	headers, signedHeaders := awsCanonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsCanonicalURI(req.URL, s.Service != "s3"),
+		awsCanonicalQuery(req.URL),
+		headers,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.Region, s.Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex(canonicalRequest)}, "\n")
+
+	key := hmacSum([]byte("AWS4"+s.secretAccessKey), date)
+	key = hmacSum(key, s.Region)
+	key = hmacSum(key, s.Service)
+	key = hmacSum(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSum(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%v/%v, SignedHeaders=%v, Signature=%v",
+		s.AccessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+// awsCanonicalURI returns the URI encoded path. Services other than S3 encode the already escaped path again.
+func awsCanonicalURI(u *url.URL, doubleEncode bool) string {
+	path := u.EscapedPath()
+	if !doubleEncode {
+		path = u.Path
+	}
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		segments[i] = awsURIEncode(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+// awsCanonicalQuery returns the query parameters encoded and sorted by name, then value.
+func awsCanonicalQuery(u *url.URL) string {
+	var params []string
+	for k, values := range u.Query() {
+		for _, v := range values {
+			params = append(params, awsURIEncode(k)+"="+awsURIEncode(v))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// awsCanonicalHeaders returns the canonical headers, each followed by a newline, and the signed header names.
+func awsCanonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	values := map[string]string{"host": host}
+	for k, v := range req.Header {
+		if strings.EqualFold(k, "Authorization") {
+			continue
+		}
+		trimmed := make([]string, len(v))
+		for i, s := range v {
+			trimmed[i] = strings.Join(strings.Fields(s), " ")
+		}
+		values[strings.ToLower(k)] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(values))
+	for k := range values {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var headers strings.Builder
+	for _, k := range names {
+		headers.WriteString(k + ":" + values[k] + "\n")
+	}
+	return headers.String(), strings.Join(names, ";")
+}
+
+// awsURIEncode encodes every byte except the unreserved characters A-Z, a-z, 0-9, '-', '.', '_' and '~'.
+func awsURIEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSum(key []byte, message string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
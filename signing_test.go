@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test cases from the AWS Signature Version 4 test suite.
+func TestAWSV4Signer(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		url       string
+		signature string
+	}{
+		{"get-vanilla", "https://example.amazonaws.com/", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"get-vanilla-query-order-key-case", "https://example.amazonaws.com/?Param2=value2&Param1=value1", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+	}
+
+	for _, test := range tests {
+		s := &AWSV4Signer{}
+		err := s.initialize(map[string]interface{}{
+			"accesskeyid":     "AKIDEXAMPLE",
+			"secretaccesskey": "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+			"region":          "us-east-1",
+			"service":         "service",
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error initializing signer: %v", err)
+		}
+		s.now = func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) }
+
+		req, _ := http.NewRequest("GET", test.url, nil)
+		err = s.sign(req, "")
+		if err != nil {
+			t.Errorf("Unexpected error signing %v: %v", test.name, err)
+			continue
+		}
+
+		expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=" + test.signature
+		if auth := req.Header.Get("Authorization"); auth != expected {
+			t.Errorf("Unexpected Authorization header for %v.\nExpected: %v\nActual:   %v", test.name, expected, auth)
+		}
+	}
+}
+
+func TestHMACSigner(t *testing.T) {
+
+	s := &HMACSigner{}
+	err := s.initialize(map[string]interface{}{"key": "key", "timestampheader": "X-Timestamp", "prefix": "sha256="})
+	if err != nil {
+		t.Fatalf("Unexpected error initializing signer: %v", err)
+	}
+	s.now = func() time.Time { return time.Unix(1500000000, 0) }
+
+	req, _ := http.NewRequest("POST", "https://www.example.com/feed?id=1", strings.NewReader("{}"))
+	s.sign(req, "{}")
+
+	if req.Header.Get("X-Timestamp") != "1500000000" {
+		t.Errorf("Expected timestamp header 1500000000 but got %v", req.Header.Get("X-Timestamp"))
+	}
+	expected := "sha256=" + hmacSHA256("key", "POST\n/feed?id=1\n1500000000\n{}")
+	if req.Header.Get("X-Signature") != expected {
+		t.Errorf("Expected signature %v but got %v", expected, req.Header.Get("X-Signature"))
+	}
+
+	s.BodyOnly = true
+	s.TimestampHeader = ""
+	req, _ = http.NewRequest("POST", "https://www.example.com/feed", strings.NewReader("{}"))
+	s.sign(req, "{}")
+	if expected = "sha256=" + hmacSHA256("key", "{}"); req.Header.Get("X-Signature") != expected {
+		t.Errorf("Expected body only signature %v but got %v", expected, req.Header.Get("X-Signature"))
+	}
+
+	if err = (&HMACSigner{}).initialize(map[string]interface{}{"key": "key", "algorithm": "md5"}); err == nil {
+		t.Error("Expected an error for an unknown algorithm.")
+	}
+}
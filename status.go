@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Status policies define how the status of each dynamic URL is combined into the status of the Endpoint.
+const (
+	StatusPolicyAll       = "all"       // The Endpoint fails if any URL fails.
+	StatusPolicyAny       = "any"       // The Endpoint is valid if any URL is valid.
+	StatusPolicyThreshold = "threshold" // The Endpoint is valid if the percentage of valid URLs meets the threshold.
+)
+
+// URLStatus is the current status of a single dynamic URL.
+type URLStatus struct {
+	URL        string
+	Label      string
+	Status     int
+	CheckTime  time.Time
+	Validation []*ValidationResult
+	Coverage   time.Duration // Time since the URL was last checked, or since it appeared if it has not been checked.
+	Overdue    bool          // The URL has not been checked within the coverage window.
+}
+
+func validateStatusPolicy(policy string, threshold int) (string, error) {
+	switch strings.ToLower(policy) {
+	case "", StatusPolicyAll:
+		return StatusPolicyAll, nil
+	case StatusPolicyAny:
+		return StatusPolicyAny, nil
+	case StatusPolicyThreshold:
+		if threshold <= 0 || threshold > 100 {
+			return "", fmt.Errorf("statusthreshold must be a percentage between 1 and 100, got %d", threshold)
+		}
+		return StatusPolicyThreshold, nil
+	}
+	return "", fmt.Errorf("unknown statuspolicy %v", policy)
+}
+
+// setURLStatus records the result for a dynamic URL and updates the aggregate status of the Endpoint. The caller must
+// hold the application lock.
+func (e *Endpoint) setURLStatus(epr *EndpointResult) {
+	status := StatusFail
+	if epr.Valid() {
+		status = StatusOK
+	}
+	e.currentURLStatus[epr.URL] = &URLStatus{URL: epr.URL, Status: status, CheckTime: epr.CheckTime, Validation: epr.ValidationResults}
+	e.CurrentStatus = e.aggregateStatus()
+}
+
+// pruneURLStatus removes the status of URLs that are no longer current and updates the aggregate status of the
+// Endpoint. The caller must hold the application lock.
+func (e *Endpoint) pruneURLStatus() {
+	current := make(map[string]bool, len(e.CurrentURLs))
+	for _, url := range e.CurrentURLs {
+		current[url] = true
+	}
+	for url := range e.currentURLStatus {
+		if !current[url] {
+			delete(e.currentURLStatus, url)
+		}
+	}
+	e.CurrentStatus = e.aggregateStatus()
+}
+
+// aggregateStatus combines the status of the current dynamic URLs according to the status policy of the Endpoint.
+// URLs that have not been checked yet are ignored.
+func (e *Endpoint) aggregateStatus() int {
+	valid, failed := 0, 0
+	for _, url := range e.CurrentURLs {
+		s, ok := e.currentURLStatus[url]
+		if !ok {
+			continue
+		}
+		if s.Status == StatusOK {
+			valid++
+		} else {
+			failed++
+		}
+	}
+
+	if valid+failed == 0 {
+		return StatusUnknown
+	}
+
+	switch e.StatusPolicy {
+	case StatusPolicyAny:
+		if valid > 0 {
+			return StatusOK
+		}
+	case StatusPolicyThreshold:
+		if valid*100 >= e.StatusThreshold*(valid+failed) {
+			return StatusOK
+		}
+	default:
+		if failed == 0 {
+			return StatusOK
+		}
+	}
+	return StatusFail
+}
+
+// URLStatuses returns the status of each current dynamic URL, in URL order. URLs that have not been checked yet have
+// an unknown status.
+func (e *Endpoint) URLStatuses() []URLStatus {
+	now := time.Now()
+	statuses := make([]URLStatus, len(e.CurrentURLs))
+	for i, url := range e.CurrentURLs {
+		if s, ok := e.currentURLStatus[url]; ok {
+			statuses[i] = *s
+		} else {
+			statuses[i] = URLStatus{URL: url, Status: StatusUnknown}
+		}
+		statuses[i].Label = e.URLLabel(url)
+		statuses[i].Coverage = now.Sub(e.lastURLCheck(url)).Round(time.Second)
+		statuses[i].Overdue = e.CoverageWindow > 0 && statuses[i].Coverage > e.CoverageWindow
+	}
+	return statuses
+}
+
+// URLStatusCounts returns the number of current dynamic URLs that are valid, failing and not yet checked.
+func (e *Endpoint) URLStatusCounts() map[string]int {
+	counts := map[string]int{"Valid": 0, "Failed": 0, "Unknown": 0}
+	for _, s := range e.URLStatuses() {
+		switch s.Status {
+		case StatusOK:
+			counts["Valid"]++
+		case StatusFail:
+			counts["Failed"]++
+		default:
+			counts["Unknown"]++
+		}
+	}
+	return counts
+}
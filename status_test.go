@@ -0,0 +1,115 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestAggregateStatus(t *testing.T) {
+
+	failed := []*ValidationResult{{Name: "Status", Errors: []string{"Invalid status"}}}
+
+	tests := []struct {
+		policy    string
+		threshold int
+		expected  int
+	}{
+		{StatusPolicyAll, 0, StatusFail},
+		{StatusPolicyAny, 0, StatusOK},
+		{StatusPolicyThreshold, 50, StatusOK},
+		{StatusPolicyThreshold, 75, StatusFail},
+	}
+
+	for _, test := range tests {
+		e := &Endpoint{
+			Dynamic:          true,
+			StatusPolicy:     test.policy,
+			StatusThreshold:  test.threshold,
+			CurrentURLs:      []string{"a", "b", "c", "d", "e"},
+			currentURLStatus: make(map[string]*URLStatus),
+		}
+		if e.aggregateStatus() != StatusUnknown {
+			t.Errorf("Expected unknown status for %v policy before any URL is checked.", test.policy)
+		}
+
+		// Two valid, two failed and one URL not checked yet.
+		e.setURLStatus(&EndpointResult{URL: "a"})
+		e.setURLStatus(&EndpointResult{URL: "b"})
+		e.setURLStatus(&EndpointResult{URL: "c", ValidationResults: failed})
+		e.setURLStatus(&EndpointResult{URL: "d", ValidationResults: failed})
+
+		if e.CurrentStatus != test.expected {
+			t.Errorf("Expected status %d for %v policy with threshold %d but got %d", test.expected, test.policy, test.threshold, e.CurrentStatus)
+		}
+	}
+}
+
+func TestPruneURLStatus(t *testing.T) {
+
+	e := &Endpoint{
+		Dynamic:          true,
+		StatusPolicy:     StatusPolicyAll,
+		CurrentURLs:      []string{"a", "b"},
+		currentURLStatus: make(map[string]*URLStatus),
+	}
+	e.setURLStatus(&EndpointResult{URL: "a"})
+	e.setURLStatus(&EndpointResult{URL: "b", ValidationResults: []*ValidationResult{{Name: "Status"}}})
+	if e.CurrentStatus != StatusFail {
+		t.Errorf("Expected failed status but got %d", e.CurrentStatus)
+	}
+
+	e.CurrentURLs = []string{"a"}
+	e.pruneURLStatus()
+	if e.CurrentStatus != StatusOK {
+		t.Errorf("Expected valid status after the failing URL was removed but got %d", e.CurrentStatus)
+	}
+	if len(e.URLStatuses()) != 1 {
+		t.Errorf("Expected 1 URL status but got %d", len(e.URLStatuses()))
+	}
+}
+
+func TestPublishResultDynamic(t *testing.T) {
+
+	results, _ := captureResults(t)
+	a := &Application{rwMu: &sync.RWMutex{}}
+	e := &Endpoint{
+		Dynamic:          true,
+		StatusPolicy:     StatusPolicyAll,
+		CurrentURLs:      []string{"a", "b"},
+		currentURLStatus: make(map[string]*URLStatus),
+	}
+
+	publishResult(a, e, &EndpointResult{URL: "a", ValidationResults: []*ValidationResult{{Name: "Status"}}})
+	publishResult(a, e, &EndpointResult{URL: "b", ValidationResults: []*ValidationResult{{Name: "Status", Valid: true}}})
+	if len(results) != 2 {
+		t.Errorf("Expected 2 stored results but got %d", len(results))
+	}
+	// The last URL was valid, but the Endpoint stays failed and the URL validation is not shown as its own.
+	if e.CurrentStatus != StatusFail || e.CurrentValidation != nil {
+		t.Errorf("Expected the failed status without current validation, got status %d and %v", e.CurrentStatus, e.CurrentValidation)
+	}
+}
+
+// captureResults replaces the result and notification channels for the duration of the test.
+func captureResults(t *testing.T) (chan *EndpointResult, chan *Notification) {
+	results, notifications := ResultLogChannel, NotificationChannel
+	ResultLogChannel = make(chan *EndpointResult, 100)
+	NotificationChannel = make(chan *Notification, 100)
+	t.Cleanup(func() {
+		ResultLogChannel, NotificationChannel = results, notifications
+	})
+	return ResultLogChannel, NotificationChannel
+}
+
+func TestValidateStatusPolicy(t *testing.T) {
+
+	if p, err := validateStatusPolicy("", 0); err != nil || p != StatusPolicyAll {
+		t.Errorf("Expected default policy all but got %v, %v", p, err)
+	}
+	if _, err := validateStatusPolicy("threshold", 0); err == nil {
+		t.Error("Expected an error for the threshold policy without a threshold.")
+	}
+	if _, err := validateStatusPolicy("most", 0); err == nil {
+		t.Error("Expected an error for an unknown policy.")
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateFuncs returns the functions available to the URL, request body and header templates of an Endpoint.
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		// Strings
+		"TrimPrefix": strings.TrimPrefix,
+		"TrimSuffix": strings.TrimSuffix,
+		"Split":      strings.Split,
+		"TrimAt": func(s string, sep string) string {
+			i := strings.Index(s, sep)
+			if i >= 0 {
+				return s[:i]
+			}
+			return s
+		},
+
+		// Dates, ex: {{Now | AddDate 0 0 -1 | FormatTime "2006-01-02"}}
+		"Now":         time.Now,
+		"FormatTime":  func(layout string, t time.Time) string { return t.Format(layout) },
+		"ParseTime":   time.Parse,
+		"AddDate":     func(years int, months int, days int, t time.Time) time.Time { return t.AddDate(years, months, days) },
+		"AddDuration": addDuration,
+		"InZone":      inZone,
+		"Unix":        func(t time.Time) int64 { return t.Unix() },
+
+		// Environment
+		"Env": env,
+
+		// Encoding
+		"Base64Encode":   func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+		"Base64Decode":   base64Decode,
+		"URLQueryEscape": url.QueryEscape,
+		"URLPathEscape":  url.PathEscape,
+		"JSON":           toJSON,
+
+		// Identifiers and hashes
+		"UUID":       newUUID,
+		"SHA256":     func(s string) string { return fmt.Sprintf("%x", sha256.Sum256([]byte(s))) },
+		"HMACSHA256": hmacSHA256,
+
+		// Math, on numbers of any type, such as the float64 values of parsed JSON.
+		"Add": func(a interface{}, b interface{}) (interface{}, error) {
+			return mathOp(a, b, func(x, y float64) float64 { return x + y })
+		},
+		"Sub": func(a interface{}, b interface{}) (interface{}, error) {
+			return mathOp(a, b, func(x, y float64) float64 { return x - y })
+		},
+		"Mul": func(a interface{}, b interface{}) (interface{}, error) {
+			return mathOp(a, b, func(x, y float64) float64 { return x * y })
+		},
+		"Div": divide,
+		"Mod": modulo,
+	}
+}
+
+// executeTemplate executes the template in value against the data.
+func executeTemplate(value string, data map[string]interface{}) (string, error) {
+	t, err := template.New("").Funcs(templateFuncs()).Parse(value)
+	if err != nil {
+		return "", err
+	}
+
+	buf := new(bytes.Buffer)
+	err = t.Execute(buf, data)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func addDuration(d string, t time.Time) (time.Time, error) {
+	duration, err := time.ParseDuration(d)
+	if err != nil {
+		return t, err
+	}
+	return t.Add(duration), nil
+}
+
+func inZone(name string, t time.Time) (time.Time, error) {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return t, err
+	}
+	return t.In(loc), nil
+}
+
+// env returns the value of an environment variable. The value is treated as a secret so it is never displayed.
+func env(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %v is not set", name)
+	}
+	registerSecret(value)
+	return value, nil
+}
+
+func base64Decode(s string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	return string(b), err
+}
+
+func toJSON(v interface{}) (string, error) {
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(v)
+	return strings.TrimSuffix(buf.String(), "\n"), err
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+func hmacSHA256(key string, message string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func divide(a interface{}, b interface{}) (interface{}, error) {
+	if y, err := toNumber(b); err == nil && y == 0 {
+		return nil, errors.New("division by zero")
+	}
+	return mathOp(a, b, func(x, y float64) float64 { return x / y })
+}
+
+func modulo(a interface{}, b interface{}) (interface{}, error) {
+	if y, err := toNumber(b); err == nil && y == 0 {
+		return nil, errors.New("division by zero")
+	}
+	return mathOp(a, b, math.Mod)
+}
+
+// mathOp applies the operation to the numbers. Whole results are returned as an int64, so they are not formatted
+// with an exponent when used in a URL.
+func mathOp(a interface{}, b interface{}, op func(float64, float64) float64) (interface{}, error) {
+	x, err := toNumber(a)
+	if err != nil {
+		return nil, err
+	}
+	y, err := toNumber(b)
+	if err != nil {
+		return nil, err
+	}
+	result := op(x, y)
+	if result == math.Trunc(result) && math.Abs(result) < math.MaxInt64 {
+		return int64(result), nil
+	}
+	return result, nil
+}
+
+func toNumber(v interface{}) (float64, error) {
+	switch tv := v.(type) {
+	case int:
+		return float64(tv), nil
+	case int64:
+		return float64(tv), nil
+	case float64:
+		return tv, nil
+	case string:
+		return strconv.ParseFloat(tv, 64)
+	case json.Number:
+		return tv.Float64()
+	}
+	return 0, fmt.Errorf("%v (%T) is not a number", v, v)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"testing"
+)
+
+func TestExecuteTemplateFuncs(t *testing.T) {
+
+	os.Setenv("FEEDMON_TEST_REGION", "us-east-1")
+	defer os.Unsetenv("FEEDMON_TEST_REGION")
+
+	data := map[string]interface{}{
+		"mainfeed": map[string]interface{}{"data": map[string]interface{}{"id": float64(1234567), "name": "a b&c"}},
+	}
+
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{`{{TrimAt "abc?x=1" "?"}}`, "abc"},
+		{`{{ParseTime "2006-01-02" "2018-03-01" | AddDate 0 0 -1 | FormatTime "2006-01-02"}}`, "2018-02-28"},
+		{`{{ParseTime "2006-01-02" "2018-03-01" | AddDuration "36h" | FormatTime "2006-01-02T15"}}`, "2018-03-02T12"},
+		{`{{ParseTime "2006-01-02" "2018-03-01" | Unix}}`, "1519862400"},
+		{`{{Env "FEEDMON_TEST_REGION"}}`, "us-east-1"},
+		{`{{Base64Encode "user:pass"}}`, "dXNlcjpwYXNz"},
+		{`{{Base64Decode "dXNlcjpwYXNz"}}`, "user:pass"},
+		{`{{URLQueryEscape .mainfeed.data.name}}`, "a+b%26c"},
+		{`{{URLPathEscape .mainfeed.data.name}}`, "a%20b&c"},
+		{`{{JSON .mainfeed.data}}`, `{"id":1234567,"name":"a b&c"}`},
+		{`{{SHA256 "abc"}}`, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{`{{HMACSHA256 "key" "The quick brown fox jumps over the lazy dog"}}`, "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+		{`{{Add .mainfeed.data.id 1}}`, "1234568"},
+		{`{{Sub 10 2.5}}`, "7.5"},
+		{`{{Mul "3" 4}}`, "12"},
+		{`{{Div 7 2}}`, "3.5"},
+		{`{{Mod 7 2}}`, "1"},
+	}
+
+	for _, test := range tests {
+		result, err := executeTemplate(test.template, data)
+		if err != nil {
+			t.Errorf("Unexpected error executing template %v: %v", test.template, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("Expected %v for template %v but got %v", test.expected, test.template, result)
+		}
+	}
+
+	uuid, err := executeTemplate("{{UUID}}", data)
+	if err != nil || !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(uuid) {
+		t.Errorf("Expected a version 4 UUID but got %v, %v", uuid, err)
+	}
+}
+
+func TestExecuteTemplateNotEscaped(t *testing.T) {
+
+	// Request bodies and headers are sent as written, without HTML escaping.
+	data := map[string]interface{}{"mainfeed": map[string]interface{}{"value": `a&b <c> "d" 'e'`}}
+	result, err := executeTemplate(`{"value": "{{.mainfeed.value}}", "raw": "x&y<z>"}`, data)
+	if err != nil {
+		t.Fatalf("Unexpected error executing template: %v", err)
+	}
+	if expected := `{"value": "a&b <c> "d" 'e'", "raw": "x&y<z>"}`; result != expected {
+		t.Errorf("Expected %v but got %v", expected, result)
+	}
+}
+
+func TestExecuteTemplateErrors(t *testing.T) {
+
+	for _, template := range []string{
+		"{{range .mainfeed}",
+		"{{Unknown .mainfeed}}",
+		"{{Div 1 0}}",
+		`{{Env "FEEDMON_TEST_UNSET"}}`,
+		`{{Add "one" 1}}`,
+	} {
+		_, err := executeTemplate(template, map[string]interface{}{})
+		if err == nil {
+			t.Errorf("Expected an error for template %v", template)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// RequestTiming contains the time spent in each phase of a request, in milliseconds.
+// When a request follows redirects, the phases of each hop are added together.
+type RequestTiming struct {
+	DNS      int64 // Resolving the host name.
+	Connect  int64 // Establishing the TCP connection.
+	TLS      int64 // Performing the TLS handshake.
+	Server   int64 // Time to first byte after the request was written.
+	Transfer int64 // Reading the response body.
+}
+
+// requestTracer collects the phase timings of a request using httptrace.
+type requestTracer struct {
+	mu           sync.Mutex
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	dns          time.Duration
+	connect      time.Duration
+	tls          time.Duration
+	server       time.Duration
+	transfer     time.Duration
+}
+
+// trace returns a copy of the request that reports its phase timings to the tracer.
+func (t *requestTracer) trace(req *http.Request) *http.Request {
+	ct := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			t.dnsStart = time.Now()
+			t.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			t.dns += time.Since(t.dnsStart)
+			t.mu.Unlock()
+		},
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			t.connectStart = time.Now()
+			t.mu.Unlock()
+		},
+		ConnectDone: func(string, string, error) {
+			t.mu.Lock()
+			t.connect += time.Since(t.connectStart)
+			t.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			t.tlsStart = time.Now()
+			t.mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			t.tls += time.Since(t.tlsStart)
+			t.mu.Unlock()
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.mu.Lock()
+			t.wroteRequest = time.Now()
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			t.firstByte = time.Now()
+			t.server += t.firstByte.Sub(t.wroteRequest)
+			t.mu.Unlock()
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), ct))
+}
+
+// bodyRead records the time the response body finished reading.
+func (t *requestTracer) bodyRead() {
+	t.mu.Lock()
+	if !t.firstByte.IsZero() {
+		t.transfer = time.Since(t.firstByte)
+	}
+	t.mu.Unlock()
+}
+
+// timing returns the collected phase timings.
+func (t *requestTracer) timing() RequestTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return RequestTiming{
+		DNS:      int64(t.dns / time.Millisecond),
+		Connect:  int64(t.connect / time.Millisecond),
+		TLS:      int64(t.tls / time.Millisecond),
+		Server:   int64(t.server / time.Millisecond),
+		Transfer: int64(t.transfer / time.Millisecond),
+	}
+}
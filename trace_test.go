@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequestTracer(t *testing.T) {
+
+	// The server waits before the first byte and again before the end of the body.
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte(`{"data": `))
+		w.(http.Flusher).Flush()
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte(`"ok"}`))
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	tracer := &requestTracer{}
+	start := time.Now()
+	resp, body, timing, err := doRequest(server.Client(), tracer.trace(req))
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatalf("Unexpected error performing request: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || string(body) != `{"data": "ok"}` {
+		t.Errorf("Expected the full body but got status %d and %q", resp.StatusCode, body)
+	}
+
+	// Both this tracer and the one in doRequest receive the trace, so the phases of a local connection that are below
+	// millisecond resolution can be checked.
+	if tracer.connect <= 0 || tracer.tls <= 0 || tracer.server <= 0 {
+		t.Errorf("Expected the connection, TLS handshake and server phases to be recorded but got %v, %v and %v", tracer.connect, tracer.tls, tracer.server)
+	}
+
+	phases := map[string]int64{"DNS": timing.DNS, "Connect": timing.Connect, "TLS": timing.TLS, "Server": timing.Server, "Transfer": timing.Transfer}
+	var sum int64
+	for name, ms := range phases {
+		if ms < 0 {
+			t.Errorf("Expected a non-negative %v phase but got %dms", name, ms)
+		}
+		sum += ms
+	}
+	if timing.Server < 100 || timing.Transfer < 100 {
+		t.Errorf("Expected at least 100ms for the server and transfer phases but got %dms and %dms", timing.Server, timing.Transfer)
+	}
+	total := int64(elapsed / time.Millisecond)
+	if sum > total || sum < total-50 {
+		t.Errorf("Expected the phases to add up to about the total of %dms but got %dms (%+v)", total, sum, timing)
+	}
+}
+
+func TestRequestTracerBodyRead(t *testing.T) {
+
+	// Without a response there is no transfer phase.
+	tracer := &requestTracer{}
+	tracer.bodyRead()
+	if timing := tracer.timing(); timing.Transfer != 0 {
+		t.Errorf("Expected no transfer time without a response but got %dms", timing.Transfer)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// TLSConfig represents the TLS settings used when connecting to an Endpoint.
+type TLSConfig struct {
+	CAFile     string // PEM bundle of additional trusted CAs.
+	CertFile   string // PEM client certificate for mutual TLS.
+	KeyFile    string // PEM private key for the client certificate.
+	MinVersion string // Minimum TLS version: 1.0, 1.1, 1.2 or 1.3
+	ServerName string // Overrides the server name used for SNI and certificate verification.
+	Insecure   *bool  // Skips verification of the server certificate. An Endpoint can set it to false to override the application.
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// merge returns a new TLSConfig with the values from o overriding the values in t.
+func (t *TLSConfig) merge(o *TLSConfig) *TLSConfig {
+	m := &TLSConfig{}
+	if t != nil {
+		*m = *t
+	}
+	if o == nil {
+		return m
+	}
+	if o.CAFile != "" {
+		m.CAFile = o.CAFile
+	}
+	if o.CertFile != "" {
+		m.CertFile = o.CertFile
+		m.KeyFile = o.KeyFile
+	}
+	if o.MinVersion != "" {
+		m.MinVersion = o.MinVersion
+	}
+	if o.ServerName != "" {
+		m.ServerName = o.ServerName
+	}
+	if o.Insecure != nil {
+		m.Insecure = o.Insecure
+	}
+	return m
+}
+
+// load reads the referenced certificate files and builds the tls.Config.
+func (t *TLSConfig) load() (*tls.Config, error) {
+	c := &tls.Config{ServerName: t.ServerName, InsecureSkipVerify: t.Insecure != nil && *t.Insecure}
+
+	if t.MinVersion != "" {
+		v, ok := tlsVersions[t.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unknown minimum TLS version %v", t.MinVersion)
+		}
+		c.MinVersion = v
+	}
+
+	if t.CAFile != "" {
+		pem, err := ioutil.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA file %v: %v", t.CAFile, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %v", t.CAFile)
+		}
+		c.RootCAs = pool
+	}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate %v and key %v: %v", t.CertFile, t.KeyFile, err)
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+
+	return c, nil
+}
+
+// newTransport creates the http.Transport used to fetch Endpoints with the provided TLS and proxy settings. Without
+// proxy settings the proxy is read from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+func newTransport(t *TLSConfig, p *ProxyConfig) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if t != nil {
+		c, err := t.load()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = c
+	}
+
+	if p != nil {
+		proxy, err := p.proxyFunc()
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = proxy
+	}
+
+	return transport, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestTLSConfigMerge(t *testing.T) {
+
+	on, off := true, false
+	app := &TLSConfig{CAFile: "ca.pem", CertFile: "app.pem", KeyFile: "app-key.pem", MinVersion: "1.2", Insecure: &on}
+
+	m := app.merge(&TLSConfig{CertFile: "endpoint.pem", KeyFile: "endpoint-key.pem", ServerName: "feeds.example.com", Insecure: &off})
+	if m.CAFile != "ca.pem" || m.MinVersion != "1.2" {
+		t.Errorf("Expected the application settings to be kept but got %+v", m)
+	}
+	if m.CertFile != "endpoint.pem" || m.KeyFile != "endpoint-key.pem" || m.ServerName != "feeds.example.com" {
+		t.Errorf("Expected the endpoint settings to override the application but got %+v", m)
+	}
+	if m.Insecure == nil || *m.Insecure {
+		t.Error("Expected the endpoint to turn off insecure.")
+	}
+	if !*app.Insecure || app.CertFile != "app.pem" {
+		t.Errorf("Expected the application settings to be unchanged but got %+v", app)
+	}
+
+	if m := app.merge(&TLSConfig{ServerName: "feeds.example.com"}); m.Insecure == nil || !*m.Insecure {
+		t.Error("Expected insecure to be inherited when the endpoint does not set it.")
+	}
+	if m := (*TLSConfig)(nil).merge(&TLSConfig{Insecure: &on}); m.Insecure == nil || !*m.Insecure {
+		t.Error("Expected the endpoint settings without application settings.")
+	}
+	if m := app.merge(nil); m.CAFile != "ca.pem" {
+		t.Errorf("Expected the application settings without endpoint settings but got %+v", m)
+	}
+}
+
+func TestTLSConfigLoad(t *testing.T) {
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+	emptyFile := filepath.Join(dir, "empty.pem")
+	ioutil.WriteFile(emptyFile, []byte("not a certificate"), 0600)
+
+	// The server certificate is only trusted with the CA file.
+	transport, err := newTransport(&TLSConfig{CAFile: caFile, MinVersion: "1.2"}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error creating transport: %v", err)
+	}
+	if _, err := (&http.Client{Transport: transport}).Get(server.URL); err != nil {
+		t.Errorf("Expected the server certificate to be trusted with the CA file: %v", err)
+	}
+	transport, _ = newTransport(&TLSConfig{}, nil)
+	if _, err := (&http.Client{Transport: transport}).Get(server.URL); err == nil {
+		t.Error("Expected the server certificate to not be trusted without the CA file.")
+	}
+
+	for _, c := range []*TLSConfig{
+		{MinVersion: "1.4"},
+		{CAFile: filepath.Join(dir, "missing.pem")},
+		{CAFile: emptyFile},
+		{CertFile: filepath.Join(dir, "missing.pem"), KeyFile: filepath.Join(dir, "missing-key.pem")},
+		{CertFile: caFile},
+	} {
+		if _, err := c.load(); err == nil {
+			t.Errorf("Expected an error loading %+v", c)
+		}
+	}
+}
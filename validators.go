@@ -3,10 +3,13 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"net"
+	"net/url"
 	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // ValidateStatus validates that the HTTP Status code is one of a set of expected values.
@@ -34,6 +37,12 @@ func (v *ValidateStatus) validate(e *Endpoint, er *EndpointResult, data map[stri
 
 	res := ValidationResult{Name: v.Name}
 
+	// A 304 confirms the previously validated body is still current.
+	if er.NotModified {
+		res.Valid = true
+		return true, &res
+	}
+
 	for _, status := range v.ValidStatusCodes {
 		if er.Status == status {
 			res.Valid = true
@@ -77,6 +86,153 @@ func (v *ValidateSize) validate(e *Endpoint, er *EndpointResult, data map[string
 	return true, &res
 }
 
+// ValidateCertificate validates the expiration and host name of the TLS certificate chain.
+type ValidateCertificate struct {
+	Name          string
+	ExpiryDays    int
+	CheckHostname bool
+}
+
+func (v *ValidateCertificate) initialize(name string, data map[string]interface{}) {
+	v.Name = name
+	v.ExpiryDays = 14
+	if days, ok := data["days"].(int); ok {
+		v.ExpiryDays = days
+	}
+	v.CheckHostname = true
+	if hostname, ok := data["hostname"].(bool); ok {
+		v.CheckHostname = hostname
+	}
+}
+
+func (v *ValidateCertificate) validate(e *Endpoint, er *EndpointResult, data map[string]interface{}) (bool, *ValidationResult) {
+
+	res := ValidationResult{Name: v.Name}
+
+	if er.TLS == nil || len(er.TLS.Certificates) == 0 {
+		res.Errors = append(res.Errors, "No TLS certificate chain was presented by the server.")
+		return true, &res
+	}
+
+	expiryLimit := er.CheckTime.AddDate(0, 0, v.ExpiryDays)
+	for i, c := range er.TLS.Certificates {
+		certType := "Intermediate"
+		if i == 0 {
+			certType = "Leaf"
+		}
+		if c.NotAfter.Before(er.CheckTime) {
+			res.Errors = append(res.Errors, fmt.Sprintf("%v certificate %v expired on %v.", certType, c.Subject, c.NotAfter.Format(time.RFC3339)))
+		} else if c.NotAfter.Before(expiryLimit) {
+			days := int(c.NotAfter.Sub(er.CheckTime).Hours() / 24)
+			res.Errors = append(res.Errors, fmt.Sprintf("%v certificate %v expires on %v, in %d days.", certType, c.Subject, c.NotAfter.Format(time.RFC3339), days))
+		}
+	}
+
+	leaf := er.TLS.Certificates[0]
+	if v.CheckHostname && !matchCertificateHost(er.TLS.ServerName, leaf) {
+		res.Errors = append(res.Errors, fmt.Sprintf("Host name %v does not match the certificate names %v %v.", er.TLS.ServerName, leaf.DNSNames, leaf.IPAddresses))
+	}
+
+	res.Valid = len(res.Errors) == 0
+
+	return true, &res
+}
+
+// matchCertificateHost returns true if the host matches one of the subject alternative names of the certificate.
+func matchCertificateHost(host string, c CertificateInfo) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	if ip := net.ParseIP(host); ip != nil {
+		for _, v := range c.IPAddresses {
+			if ip.Equal(net.ParseIP(v)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, name := range c.DNSNames {
+		name = strings.ToLower(strings.TrimSuffix(name, "."))
+		if name == host {
+			return true
+		}
+		// Wildcards only match a single label.
+		if strings.HasPrefix(name, "*.") {
+			i := strings.Index(host, ".")
+			if i > 0 && host[i:] == name[1:] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidateRedirect validates the redirects followed to reach the final response.
+type ValidateRedirect struct {
+	Name      string
+	MaxHops   int
+	FinalHost string
+	HTTPSOnly bool
+}
+
+func (v *ValidateRedirect) initialize(name string, data map[string]interface{}) {
+	v.Name = name
+	v.MaxHops = -1
+	if maxHops, ok := data["maxhops"].(int); ok {
+		v.MaxHops = maxHops
+	}
+	if finalHost, ok := data["finalhost"].(string); ok {
+		v.FinalHost = finalHost
+	}
+	if httpsOnly, ok := data["httpsonly"].(bool); ok {
+		v.HTTPSOnly = httpsOnly
+	}
+}
+
+func (v *ValidateRedirect) validate(e *Endpoint, er *EndpointResult, data map[string]interface{}) (bool, *ValidationResult) {
+
+	res := ValidationResult{Name: v.Name}
+
+	if v.MaxHops >= 0 && len(er.Redirects) > v.MaxHops {
+		res.Errors = append(res.Errors, fmt.Sprintf("Request followed %d redirects, more than the maximum of %d.", len(er.Redirects), v.MaxHops))
+	}
+
+	// Build the chain of URLs requested, ending with the URL of the final response. A redirect that was not followed,
+	// with ignoreredirects, is not part of the chain.
+	var chain []string
+	for _, hop := range er.Redirects {
+		chain = append(chain, hop.URL)
+	}
+	final := er.FinalURL
+	if final == "" {
+		final = er.URL
+	}
+	if len(chain) == 0 || chain[len(chain)-1] != final {
+		chain = append(chain, final)
+	}
+
+	if v.HTTPSOnly {
+		for _, u := range chain {
+			if !strings.HasPrefix(strings.ToLower(u), "https://") {
+				res.Errors = append(res.Errors, fmt.Sprintf("Redirect chain includes non-HTTPS URL %v.", u))
+			}
+		}
+	}
+
+	if v.FinalHost != "" {
+		final, err := url.Parse(chain[len(chain)-1])
+		if err != nil {
+			res.Errors = append(res.Errors, fmt.Sprintf("Unable to parse final URL %v: %v", chain[len(chain)-1], err))
+		} else if !strings.EqualFold(final.Hostname(), v.FinalHost) {
+			res.Errors = append(res.Errors, fmt.Sprintf("Final host %v does not match expected host %v.", final.Hostname(), v.FinalHost))
+		}
+	}
+
+	res.Valid = len(res.Errors) == 0
+
+	return true, &res
+}
+
 // ValidateJSON provides validation of JSON files.
 type ValidateJSON struct {
 	Name string
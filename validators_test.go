@@ -3,6 +3,7 @@ package main
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestValidateJSONDataMissingKeySimple(t *testing.T) {
@@ -315,3 +316,156 @@ func validate(t *testing.T, j *ValidateJSONData, keys []string, command string,
 	}
 
 }
+
+func TestValidateCertificate(t *testing.T) {
+
+	v := &ValidateCertificate{}
+
+	config := make(map[string]interface{})
+	config["days"] = 30
+
+	v.initialize("Test Validator", config)
+
+	checkTime := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	endpoint := &Endpoint{Name: "Test Endpoint"}
+	endpointResult := &EndpointResult{CheckTime: checkTime}
+	endpointResult.TLS = &TLSInfo{
+		ServerName: "feeds.example.com",
+		Certificates: []CertificateInfo{
+			{Subject: "CN=feeds.example.com", DNSNames: []string{"feeds.example.com"}, NotAfter: checkTime.AddDate(0, 6, 0)},
+			{Subject: "CN=Example CA", NotAfter: checkTime.AddDate(2, 0, 0)},
+		},
+	}
+
+	_, res := v.validate(endpoint, endpointResult, nil)
+	if !res.Valid {
+		t.Errorf("Valid certificate chain sent to ValidateCertificate but recieved errors: %v", res.Errors)
+	}
+
+	endpointResult.TLS.Certificates[1].NotAfter = checkTime.AddDate(0, 0, 10)
+	_, res = v.validate(endpoint, endpointResult, nil)
+	if res.Valid {
+		t.Error("Intermediate certificate expiring within 30 days sent to ValidateCertificate but didn't recieve an error.")
+	}
+
+	endpointResult.TLS.Certificates[1].NotAfter = checkTime.AddDate(2, 0, 0)
+	endpointResult.TLS.ServerName = "other.example.com"
+	_, res = v.validate(endpoint, endpointResult, nil)
+	if res.Valid {
+		t.Error("Mismatched host name sent to ValidateCertificate but didn't recieve an error.")
+	}
+
+	endpointResult.TLS = nil
+	_, res = v.validate(endpoint, endpointResult, nil)
+	if res.Valid {
+		t.Error("Result without a certificate chain sent to ValidateCertificate but didn't recieve an error.")
+	}
+}
+
+func TestMatchCertificateHost(t *testing.T) {
+
+	c := CertificateInfo{DNSNames: []string{"example.com", "*.feeds.example.com"}, IPAddresses: []string{"10.0.0.1"}}
+
+	tests := map[string]bool{
+		"example.com":           true,
+		"EXAMPLE.com.":          true,
+		"www.example.com":       false,
+		"a.feeds.example.com":   true,
+		"a.b.feeds.example.com": false,
+		"feeds.example.com":     false,
+		"10.0.0.1":              true,
+		"10.0.0.2":              false,
+	}
+
+	for host, expected := range tests {
+		if matchCertificateHost(host, c) != expected {
+			t.Errorf("Expected matchCertificateHost for host %v to be %v", host, expected)
+		}
+	}
+}
+
+func TestValidateStatusNotModified(t *testing.T) {
+
+	v := &ValidateStatus{}
+
+	config := make(map[string]interface{})
+	config["status"] = 200
+
+	v.initialize("Test Validator", config)
+
+	endpoint := &Endpoint{Name: "Test Endpoint"}
+	endpointResult := &EndpointResult{Status: 304}
+
+	_, res := v.validate(endpoint, endpointResult, nil)
+	if res.Valid {
+		t.Error("Unexpected 304 status sent to ValidateStatus but didn't recieve an error.")
+	}
+
+	endpointResult.NotModified = true
+	_, res = v.validate(endpoint, endpointResult, nil)
+	if !res.Valid {
+		t.Errorf("Conditional 304 status sent to ValidateStatus but recieved errors: %v", res.Errors)
+	}
+}
+
+func TestValidateRedirect(t *testing.T) {
+
+	v := &ValidateRedirect{}
+
+	config := make(map[string]interface{})
+	config["maxhops"] = 1
+	config["finalhost"] = "cdn.example.com"
+	config["httpsonly"] = true
+
+	v.initialize("Test Validator", config)
+
+	endpoint := &Endpoint{Name: "Test Endpoint"}
+	endpointResult := &EndpointResult{URL: "https://www.example.com/feed.json", FinalURL: "https://cdn.example.com/feed.json"}
+	endpointResult.Redirects = []RedirectHop{
+		{URL: "https://www.example.com/feed.json", Status: 302, Location: "https://cdn.example.com/feed.json"},
+	}
+
+	_, res := v.validate(endpoint, endpointResult, nil)
+	if !res.Valid {
+		t.Errorf("Valid redirect chain sent to ValidateRedirect but recieved errors: %v", res.Errors)
+	}
+
+	endpointResult.Redirects[0].Location = "http://cdn.example.com/feed.json"
+	endpointResult.FinalURL = "http://cdn.example.com/feed.json"
+	_, res = v.validate(endpoint, endpointResult, nil)
+	if res.Valid {
+		t.Error("Redirect to HTTP sent to ValidateRedirect but didn't recieve an error.")
+	}
+
+	endpointResult.Redirects[0].Location = "/other/feed.json"
+	endpointResult.FinalURL = "https://www.example.com/other/feed.json"
+	_, res = v.validate(endpoint, endpointResult, nil)
+	if res.Valid {
+		t.Error("Redirect to unexpected host sent to ValidateRedirect but didn't recieve an error.")
+	}
+
+	// With ignoreredirects the redirect is recorded but not followed, so the final host is the requested host.
+	endpointResult.Redirects[0].Location = "https://cdn.example.com/feed.json"
+	endpointResult.FinalURL = "https://www.example.com/feed.json"
+	_, res = v.validate(endpoint, endpointResult, nil)
+	if res.Valid {
+		t.Error("Redirect that was not followed sent to ValidateRedirect but didn't recieve an error.")
+	}
+
+	// A redacted location does not affect the chain.
+	endpointResult.Redirects[0].Location = "https://******/feed.json"
+	endpointResult.FinalURL = "https://cdn.example.com/feed.json"
+	_, res = v.validate(endpoint, endpointResult, nil)
+	if !res.Valid {
+		t.Errorf("Redirect with a redacted location sent to ValidateRedirect but recieved errors: %v", res.Errors)
+	}
+
+	endpointResult.Redirects = []RedirectHop{
+		{URL: "https://www.example.com/feed.json", Status: 301, Location: "https://www2.example.com/feed.json"},
+		{URL: "https://www2.example.com/feed.json", Status: 302, Location: "https://cdn.example.com/feed.json"},
+	}
+	_, res = v.validate(endpoint, endpointResult, nil)
+	if res.Valid {
+		t.Error("Too many redirects sent to ValidateRedirect but didn't recieve an error.")
+	}
+}
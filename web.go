@@ -7,8 +7,10 @@ import (
 	"fmt"
 	"html/template"
 	"net/http"
+	"net/url"
 	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -41,7 +43,11 @@ func StartWebserver(ctx context.Context, wg *sync.WaitGroup, logger *logrus.Entr
 	r.Handle("/css/{rest}", http.StripPrefix("/css/", http.FileServer(http.Dir("web/css"))))
 	r.Handle("/fonts/{rest}", http.StripPrefix("/fonts/", http.FileServer(http.Dir("web/fonts"))))
 	r.HandleFunc("/app/{app}/", appHome)
+	r.HandleFunc("/app/{app}/maintenance", addMaintenanceWindow).Methods("POST")
+	r.HandleFunc("/app/{app}/maintenance/delete", deleteMaintenanceWindow).Methods("POST")
 	r.HandleFunc("/app/{app}/{endpoint}/", endpointHome)
+	r.HandleFunc("/app/{app}/{endpoint}/check", endpointCheck).Methods("POST")
+	r.HandleFunc("/app/{app}/{endpoint}/urls", endpointURLs)
 	r.HandleFunc("/app/{app}/{endpoint}/result", endpointResult)
 	r.HandleFunc("/app/{app}/{endpoint}/results", endpointResults)
 	r.HandleFunc("/app/{app}/{endpoint}/resultsdiff", endpointResultsDiff)
@@ -144,9 +150,192 @@ func appHome(w http.ResponseWriter, r *http.Request) {
 	templateData["Applications"] = applications
 	templateData["Application"] = app
 
+	// The dependency tree starts from the Endpoints that have dependents but do not depend on any other Endpoint.
+	var roots []*Endpoint
+	for _, e := range app.Endpoints {
+		if len(e.DependsOn) == 0 && len(e.Dependents()) > 0 {
+			roots = append(roots, e)
+		}
+	}
+	templateData["DependencyRoots"] = roots
+
+	// The per-URL statuses are read while the lock is held, as the checks update them concurrently.
+	urlStatuses := make(map[string][]URLStatus)
+	app.rwMu.RLock()
+	for _, e := range app.Endpoints {
+		if e.Dynamic {
+			urlStatuses[e.Key] = e.URLStatuses()
+		}
+	}
+	templateData["Maintenance"] = app.MaintenanceWindows(nil)
+	app.rwMu.RUnlock()
+	templateData["URLStatuses"] = urlStatuses
+
 	renderTemplate(w, r, "appHome", templateData)
 }
 
+// addMaintenanceWindow creates a maintenance window for the application, or for one of its Endpoints, from the form on
+// the application and Endpoint pages.
+func addMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	app := configuration.getApplication(mux.Vars(r)["app"])
+	if app == nil {
+		notFoundHandler(w, r)
+		return
+	}
+
+	c := MaintenanceConfig{
+		Endpoint: r.FormValue("endpoint"),
+		Name:     r.FormValue("name"),
+		Start:    r.FormValue("start"),
+		End:      r.FormValue("end"),
+		Cron:     r.FormValue("cron"),
+		TimeZone: r.FormValue("timezone"),
+		Mode:     r.FormValue("mode"),
+	}
+	if c.Endpoint != "" && app.getEndpoint(c.Endpoint) == nil {
+		notFoundHandler(w, r)
+		return
+	}
+	if d := r.FormValue("duration"); d != "" && c.Cron != "" {
+		var err error
+		c.Duration, err = time.ParseDuration(d)
+		if err != nil {
+			invalidFormHandler(w, r, fmt.Sprintf("Invalid duration %v", d))
+			return
+		}
+	}
+
+	window, err := newMaintenanceWindow(c)
+	if err != nil {
+		invalidFormHandler(w, r, fmt.Sprintf("Invalid maintenance window: %v", err))
+		return
+	}
+	err = PutMaintenanceWindow(app.Key, &c)
+	if err != nil {
+		errorHandler(w, r, fmt.Sprintf("Unable to store the maintenance window: %v", err))
+		return
+	}
+	window.ID = c.ID
+
+	app.rwMu.Lock()
+	err = app.addMaintenance(window)
+	app.rwMu.Unlock()
+	if err != nil {
+		errorHandler(w, r, err.Error())
+		return
+	}
+
+	redirectToPage(w, "./", c.Endpoint)
+}
+
+// deleteMaintenanceWindow removes a maintenance window created in the web interface.
+func deleteMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	app := configuration.getApplication(mux.Vars(r)["app"])
+	if app == nil {
+		notFoundHandler(w, r)
+		return
+	}
+
+	id := r.FormValue("id")
+	if id == "" {
+		invalidFormHandler(w, r, "Missing maintenance window id")
+		return
+	}
+	err := DeleteMaintenanceWindow(app.Key, id)
+	if err != nil {
+		errorHandler(w, r, fmt.Sprintf("Unable to delete the maintenance window: %v", err))
+		return
+	}
+
+	app.rwMu.Lock()
+	app.removeMaintenance(id)
+	app.rwMu.Unlock()
+
+	redirectToPage(w, "../", r.FormValue("endpoint"))
+}
+
+// checkResponse is returned by a synchronous on-demand check.
+type checkResponse struct {
+	App      string
+	Endpoint string
+	Status   string // valid, error or unknown
+	Valid    bool
+	Results  []*EndpointResult
+}
+
+// endpointCheck checks the Endpoint immediately. By default the check runs in the background and the request is
+// redirected to the Endpoint page. With sync=true the request waits for the check and returns the results as JSON.
+func endpointCheck(w http.ResponseWriter, r *http.Request) {
+	found, app, endpoint := getAppEndpoint(w, r)
+	if !found {
+		notFoundHandler(w, r)
+		return
+	}
+
+	wait, _ := strconv.ParseBool(r.FormValue("sync"))
+	var results []*EndpointResult
+	var collect *[]*EndpointResult
+	if wait {
+		collect = &results
+	}
+	wg, err := app.startOnDemandCheck(endpoint, collect)
+	if err != nil {
+		webLog.Debugf("Rendering 409 for URL %s", r.RequestURI)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprint(w, err.Error())
+		return
+	}
+
+	if !wait {
+		go app.runCheck(wg, endpoint)
+		redirectToPage(w, "../", endpoint.Key)
+		return
+	}
+	app.runCheck(wg, endpoint)
+
+	// The result writer updates the results as it stores them, so they are only read once it is done.
+	for _, epr := range results {
+		select {
+		case <-epr.stored:
+		case <-app.cancel:
+			errorHandler(w, r, "The application was stopped before the check results were stored.")
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	app.rwMu.RLock()
+	resp := checkResponse{App: app.Key, Endpoint: endpoint.Key, Status: "unknown", Valid: endpoint.CurrentStatus == StatusOK, Results: results}
+	switch endpoint.CurrentStatus {
+	case StatusOK:
+		resp.Status = "valid"
+	case StatusFail:
+		resp.Status = "error"
+	}
+	b, err := json.MarshalIndent(resp, "", "  ")
+	app.rwMu.RUnlock()
+	if err != nil {
+		errorHandler(w, r, fmt.Sprintf("Unable to encode the check results: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, redactSecrets(string(b)))
+}
+
+// redirectToPage redirects a form submission back to the application page, or the Endpoint page if an Endpoint key
+// is provided. The location is relative to the application, so it works when the web interface is behind a proxy.
+func redirectToPage(w http.ResponseWriter, appPath string, endpoint string) {
+	location := appPath
+	if endpoint != "" {
+		location += url.PathEscape(endpoint) + "/"
+	}
+	w.Header().Set("Location", location)
+	w.WriteHeader(http.StatusSeeOther)
+}
+
 func endpointHome(w http.ResponseWriter, r *http.Request) {
 	initTemplates()
 
@@ -158,6 +347,7 @@ func endpointHome(w http.ResponseWriter, r *http.Request) {
 
 	templateData := make(map[string]interface{})
 	urls := []string{}
+	labels := make(map[string]string)
 	recentResults := make(map[string][]EndpointResult)
 	templateData["Applications"] = applications
 	templateData["Application"] = app
@@ -167,6 +357,7 @@ func endpointHome(w http.ResponseWriter, r *http.Request) {
 	if endpoint.Dynamic {
 		for _, url := range endpoint.CurrentURLs {
 			urls = append(urls, url)
+			labels[url] = endpoint.URLLabel(url)
 			res, _ := GetLastNEndpointResult(app.Key, endpoint.Key, url, 10)
 			if res != nil {
 				recentResults[url] = res
@@ -174,16 +365,58 @@ func endpointHome(w http.ResponseWriter, r *http.Request) {
 		}
 	} else {
 		urls = append(urls, endpoint.URL)
+		labels[endpoint.URL] = endpoint.URL
 		recentResults[endpoint.URL], _ = GetLastNEndpointResult(app.Key, endpoint.Key, endpoint.URL, 10)
 	}
+	// The checks update the per-URL statuses, labels and schedule concurrently, so the template is given a copy.
+	if endpoint.Dynamic {
+		templateData["URLStatuses"] = endpoint.URLStatuses()
+		templateData["URLStatusCounts"] = endpoint.URLStatusCounts()
+	}
+	templateData["UpcomingChecks"] = endpoint.UpcomingChecks(5)
+	templateData["Failing"] = endpoint.Failing
+	templateData["Passes"] = endpoint.Passes
+	templateData["Maintenance"] = app.MaintenanceWindows(endpoint)
 	app.rwMu.RUnlock()
 
 	templateData["URLS"] = urls
+	templateData["URLLabels"] = labels
 	templateData["Results"] = recentResults
 
 	renderTemplate(w, r, "endpointHome", templateData)
 }
 
+func endpointURLs(w http.ResponseWriter, r *http.Request) {
+	initTemplates()
+	found, app, endpoint := getAppEndpoint(w, r)
+	if !found || !endpoint.Dynamic {
+		notFoundHandler(w, r)
+		return
+	}
+
+	urls, err := GetDynamicURLs(app.Key, endpoint.Key)
+	if err != nil {
+		errorHandler(w, r, err.Error())
+		return
+	}
+
+	// Show the current URLs first, then the retired URLs with the most recently retired first.
+	sort.SliceStable(urls, func(i, j int) bool {
+		if urls[i].Retired != urls[j].Retired {
+			return !urls[i].Retired
+		}
+		return urls[i].RetiredTime.After(urls[j].RetiredTime)
+	})
+
+	templateData := make(map[string]interface{})
+	templateData["Applications"] = applications
+	templateData["Application"] = app
+	templateData["Endpoint"] = endpoint
+	templateData["URLs"] = urls
+
+	renderTemplate(w, r, "endpointURLs", templateData)
+}
+
 func endpointResult(w http.ResponseWriter, r *http.Request) {
 	initTemplates()
 	found, app, endpoint := getAppEndpoint(w, r)
@@ -200,8 +433,10 @@ func endpointResult(w http.ResponseWriter, r *http.Request) {
 
 	app.rwMu.RLock()
 	url := getURL(endpoint, r)
+	label := endpoint.URLLabel(url)
 
 	epr, err := GetEndpointResult(app.Key, endpoint.Key, url, date)
+	app.rwMu.RUnlock()
 	if err != nil {
 		errorHandler(w, r, err.Error())
 		return
@@ -209,13 +444,13 @@ func endpointResult(w http.ResponseWriter, r *http.Request) {
 		notFoundHandler(w, r)
 		return
 	}
-	app.rwMu.RUnlock()
 
 	templateData := make(map[string]interface{})
 	templateData["Applications"] = applications
 	templateData["Application"] = app
 	templateData["Endpoint"] = endpoint
 	templateData["FeedURL"] = url
+	templateData["FeedLabel"] = label
 	templateData["Result"] = epr
 
 	renderTemplate(w, r, "endpointResult", templateData)
@@ -238,6 +473,8 @@ func endpointResults(w http.ResponseWriter, r *http.Request) {
 	app.rwMu.RLock()
 	url := getURL(endpoint, r)
 
+	label := endpoint.URLLabel(url)
+
 	results, _ := GetEndpointResultsForDate(app.Key, endpoint.Key, url, date)
 	app.rwMu.RUnlock()
 
@@ -250,6 +487,7 @@ func endpointResults(w http.ResponseWriter, r *http.Request) {
 	templateData["NextDate"] = date.Add(24 * time.Hour)
 	templateData["PrevDate"] = date.Add(-24 * time.Hour)
 	templateData["FeedURL"] = url
+	templateData["FeedLabel"] = label
 
 	renderTemplate(w, r, "endpointResults", templateData)
 }
@@ -265,6 +503,8 @@ func endpointResultsDiff(w http.ResponseWriter, r *http.Request) {
 	app.rwMu.RLock()
 	url := getURL(endpoint, r)
 
+	label := endpoint.URLLabel(url)
+
 	results, _ := GetLastNDiffEndpointResult(app.Key, endpoint.Key, url, 100)
 	app.rwMu.RUnlock()
 
@@ -274,6 +514,7 @@ func endpointResultsDiff(w http.ResponseWriter, r *http.Request) {
 	templateData["Endpoint"] = endpoint
 	templateData["Results"] = results
 	templateData["FeedURL"] = url
+	templateData["FeedLabel"] = label
 	templateData["FilterName"] = "Diffs"
 
 	renderTemplate(w, r, "endpointResultsAlt", templateData)
@@ -290,6 +531,8 @@ func endpointResultsInvalid(w http.ResponseWriter, r *http.Request) {
 	app.rwMu.RLock()
 	url := getURL(endpoint, r)
 
+	label := endpoint.URLLabel(url)
+
 	results, _ := GetLastNInvalidEndpointResult(app.Key, endpoint.Key, url, 100)
 	app.rwMu.RUnlock()
 
@@ -299,6 +542,7 @@ func endpointResultsInvalid(w http.ResponseWriter, r *http.Request) {
 	templateData["Endpoint"] = endpoint
 	templateData["Results"] = results
 	templateData["FeedURL"] = url
+	templateData["FeedLabel"] = label
 	templateData["FilterName"] = "Invalid Results"
 
 	renderTemplate(w, r, "endpointResultsAlt", templateData)
@@ -321,6 +565,8 @@ func endpointPerformance(w http.ResponseWriter, r *http.Request) {
 	app.rwMu.RLock()
 	url := getURL(endpoint, r)
 
+	label := endpoint.URLLabel(url)
+
 	perfRecs, err := GetPerformanceRecordsForDate(app.Key, endpoint.Key, url, date)
 	app.rwMu.RUnlock()
 
@@ -343,8 +589,10 @@ func endpointPerformance(w http.ResponseWriter, r *http.Request) {
 	templateData["Application"] = app
 	templateData["Endpoint"] = endpoint
 	templateData["FeedURL"] = url
+	templateData["FeedLabel"] = label
 	templateData["Date"] = date.Format("Mon Jan _2 2006")
 	templateData["graphData"] = template.JS(buildGraphMapString(perfRecs))
+	templateData["timingData"] = template.JS(buildTimingGraphString(perfRecs))
 	templateData["StartDate"] = template.JS(fmt.Sprintf("new Date(%d, %d, %d, 0, 0)", d.Year(), d.Month()-1, d.Day()))
 	templateData["EndDate"] = template.JS(fmt.Sprintf("new Date(%d, %d, %d, 0, 0)", tom.Year(), tom.Month()-1, tom.Day()))
 	templateData["NextDate"] = date.Add(24 * time.Hour)
@@ -401,6 +649,7 @@ func endpointDiff(w http.ResponseWriter, r *http.Request) {
 
 	app.rwMu.RLock()
 	url := getURL(endpoint, r)
+	app.rwMu.RUnlock()
 
 	epr, err := GetEndpointResult(app.Key, endpoint.Key, url, date)
 	if err != nil {
@@ -414,7 +663,6 @@ func endpointDiff(w http.ResponseWriter, r *http.Request) {
 	}
 
 	oldEpr, err := GetEndpointResultPrev(app.Key, endpoint.Key, url, date)
-	app.rwMu.RUnlock()
 	if err != nil {
 		errorHandler(w, r, err.Error())
 		return
@@ -452,11 +700,18 @@ func badRequestHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusBadRequest)
 }
 
+func invalidFormHandler(w http.ResponseWriter, r *http.Request, errorDesc string) {
+	webLog.Debugf("Rendering 400 for URL %s", r.RequestURI)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusBadRequest)
+	fmt.Fprint(w, errorDesc)
+}
+
 func errorHandler(w http.ResponseWriter, r *http.Request, errorDesc string) {
 	webLog.Debugf("Rendering 500 for URL %s", r.RequestURI)
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusInternalServerError)
-	fmt.Fprintf(w, "Server Error: %v", errorDesc)
+	fmt.Fprintf(w, "Server Error: %v", redactSecrets(errorDesc))
 }
 
 func renderTemplate(w http.ResponseWriter, r *http.Request, name string, data map[string]interface{}) {
@@ -475,11 +730,16 @@ func renderTemplate(w http.ResponseWriter, r *http.Request, name string, data ma
 
 	webLog.Debugf("Rendering template name %s", tmpl.Name())
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	err := tmpl.ExecuteTemplate(w, name+".tmpl", data)
+	// Render to a buffer so any secrets from the configuration can be redacted before they are sent.
+	var buf bytes.Buffer
+	err := tmpl.ExecuteTemplate(&buf, name+".tmpl", data)
 	if err != nil {
 		errorHandler(w, r, fmt.Sprintf("Unable to Excecute Template %v. Error: %v", name, err))
+		return
 	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(redactSecrets(buf.String())))
 }
 
 func renderEndpointReplay(w http.ResponseWriter, r *http.Request, epr *EndpointResult) {
@@ -546,3 +806,16 @@ func buildGraphMapString(perfRecs []PerformanceEntryResult) (result string) {
 	}
 	return
 }
+
+func buildTimingGraphString(perfRecs []PerformanceEntryResult) (result string) {
+
+	delim := ""
+	for i, v := range perfRecs {
+		if i > 0 {
+			delim = ", "
+		}
+		t := v.Timing
+		result += fmt.Sprintf("%v[new Date(%d000), %d, %d, %d, %d, %d]", delim, v.CheckTime.Unix(), t.DNS, t.Connect, t.TLS, t.Server, t.Transfer)
+	}
+	return
+}
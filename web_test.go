@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestStartOnDemandCheck(t *testing.T) {
+
+	s, _ := parseSchedule("10m", "")
+	mainfeed := &Endpoint{Key: "mainfeed", Name: "Main Feed", Schedule: s}
+	detail := &Endpoint{Key: "detailfeed", Name: "Detail Feed", Schedule: s, DependsOn: []string{"mainfeed"}}
+	a := &Application{Endpoints: []*Endpoint{mainfeed, detail}, rwMu: &sync.RWMutex{}, data: make(map[string]interface{})}
+
+	var results []*EndpointResult
+	if _, err := a.startOnDemandCheck(mainfeed, &results); err == nil {
+		t.Error("Expected an error for an application that is not being monitored.")
+	}
+	a.monitorWg = &sync.WaitGroup{}
+	wg, err := a.startOnDemandCheck(mainfeed, &results)
+	if err != nil {
+		t.Fatalf("Unexpected error starting the check: %v", err)
+	}
+	if wg != a.monitorWg {
+		t.Error("Expected the check to be added to the WaitGroup of the Feed Checker.")
+	}
+	if !mainfeed.checking || mainfeed.checkResults != &results || !mainfeed.nextCheckTime.After(time.Now()) {
+		t.Errorf("Expected the check to start and the next check to be scheduled, checking %v, next check %v", mainfeed.checking, mainfeed.nextCheckTime)
+	}
+	if _, err := a.startOnDemandCheck(mainfeed, nil); err == nil {
+		t.Error("Expected an error for an endpoint that is already being checked.")
+	}
+	if _, err := a.startOnDemandCheck(detail, nil); err == nil {
+		t.Error("Expected an error for an endpoint whose dependencies have no data.")
+	}
+}
+
+// newTestCheckRouter serves the on-demand check handler for the application, and marks the results of the checks as
+// stored the way the result writer does.
+func newTestCheckRouter(t *testing.T, a *Application, results chan *EndpointResult) *mux.Router {
+	webLog = log
+	apps := applications
+	applications = []*Application{a}
+	done := make(chan bool)
+	t.Cleanup(func() {
+		close(done)
+		applications = apps
+	})
+
+	go func() {
+		for {
+			select {
+			case epr := <-results:
+				if epr.stored != nil {
+					close(epr.stored)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	r := mux.NewRouter()
+	r.HandleFunc("/app/{app}/{endpoint}/check", endpointCheck).Methods("POST")
+	return r
+}
+
+func TestEndpointCheck(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": "ok"}`))
+	}))
+	defer server.Close()
+
+	a, e, results := newTestEndpoint(t, server.URL)
+	e.Schedule, _ = parseSchedule("10m", "")
+	a.monitorWg = &sync.WaitGroup{}
+	r := newTestCheckRouter(t, a, results)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/app/app/mainfeed/check?sync=true", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("Expected a JSON response but got status %d, %v", w.Code, w.Body.String())
+	}
+	var resp checkResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Unable to decode the response: %v", err)
+	}
+	if resp.App != "app" || resp.Endpoint != "mainfeed" || resp.Status != "valid" || !resp.Valid {
+		t.Errorf("Expected a valid check of app/mainfeed but got %+v", resp)
+	}
+	if len(resp.Results) != 1 || resp.Results[0].Status != http.StatusOK || resp.Results[0].URL != server.URL {
+		t.Errorf("Expected the result of the check but got %+v", resp.Results)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/app/app/unknown/check?sync=true", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown endpoint but got %d", w.Code)
+	}
+}
+
+func TestEndpointCheckConflict(t *testing.T) {
+
+	a, e, results := newTestEndpoint(t, "http://www.example.com/")
+	e.Schedule, _ = parseSchedule("10m", "")
+	a.monitorWg = &sync.WaitGroup{}
+	r := newTestCheckRouter(t, a, results)
+
+	a.rwMu.Lock()
+	e.checking = true
+	a.rwMu.Unlock()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/app/app/mainfeed/check?sync=true", nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for an endpoint that is already being checked but got %d: %v", w.Code, w.Body.String())
+	}
+}